@@ -0,0 +1,96 @@
+package lambtrip
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultTargetGroupARN is a dummy ARN of the target group.
+const defaultTargetGroupARN = "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/lambtrip/0123456789abcdef"
+
+// requestALB is the event format of Application Load Balancer targets.
+type requestALB struct {
+	RequestContext                  *requestContextALB  `json:"requestContext"`
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters,omitempty"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters,omitempty"`
+	Headers                         map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+}
+
+type requestContextALB struct {
+	ELB *requestContextELB `json:"elb"`
+}
+
+type requestContextELB struct {
+	TargetGroupArn string `json:"targetGroupArn"`
+}
+
+func buildRequestALB(req *http.Request, multiValueHeaders bool) (*requestALB, error) {
+	// build the body
+	body, isBase64Encoded, err := buildBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &requestALB{
+		RequestContext: &requestContextALB{
+			ELB: &requestContextELB{
+				TargetGroupArn: defaultTargetGroupARN,
+			},
+		},
+		HTTPMethod:      req.Method,
+		Path:            req.URL.EscapedPath(),
+		Body:            body,
+		IsBase64Encoded: isBase64Encoded,
+	}
+
+	// ALB doesn't decode the query string parameters.
+	// They are passed to the function as they are sent by the client.
+	query := parseRawQuery(req.URL.RawQuery)
+
+	// ALB sends the header names in lower case.
+	if multiValueHeaders {
+		r.MultiValueHeaders = make(map[string][]string, len(req.Header))
+		for k, v := range req.Header {
+			name := strings.ToLower(k)
+			r.MultiValueHeaders[name] = append(r.MultiValueHeaders[name], v...)
+		}
+		r.MultiValueQueryStringParameters = make(map[string][]string, len(query))
+		for _, kv := range query {
+			r.MultiValueQueryStringParameters[kv[0]] = append(r.MultiValueQueryStringParameters[kv[0]], kv[1])
+		}
+	} else {
+		// if the client sends duplicate headers or query string parameters, ALB uses the last value.
+		r.Headers = make(map[string]string, len(req.Header))
+		for k, v := range req.Header {
+			if len(v) == 0 {
+				continue
+			}
+			r.Headers[strings.ToLower(k)] = v[len(v)-1]
+		}
+		r.QueryStringParameters = make(map[string]string, len(query))
+		for _, kv := range query {
+			r.QueryStringParameters[kv[0]] = kv[1]
+		}
+	}
+	return r, nil
+}
+
+// parseRawQuery splits the raw query string into key-value pairs without decoding.
+func parseRawQuery(rawQuery string) [][2]string {
+	var query [][2]string
+	for rawQuery != "" {
+		var kv string
+		kv, rawQuery, _ = strings.Cut(rawQuery, "&")
+		if kv == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(kv, "=")
+		query = append(query, [2]string{k, v})
+	}
+	return query
+}
@@ -0,0 +1,140 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestBufferedTransport_ALB(t *testing.T) {
+	transport := &BufferedTransport{
+		Codec: EventFormatALB,
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req requestALB
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if req.RequestContext.ELB.TargetGroupArn == "" {
+				t.Errorf("req.RequestContext.ELB.TargetGroupArn = %q, want non-empty", req.RequestContext.ELB.TargetGroupArn)
+			}
+			if req.HTTPMethod != http.MethodGet {
+				t.Errorf("req.HTTPMethod = %q, want %q", req.HTTPMethod, http.MethodGet)
+			}
+			if req.Path != "/foo/bar" {
+				t.Errorf("req.Path = %q, want %q", req.Path, "/foo/bar")
+			}
+			if got := req.Headers["x-foo"]; got != "bar2" {
+				t.Errorf("req.Headers[%q] = %q, want %q", "x-foo", got, "bar2")
+			}
+			if got := req.QueryStringParameters["q"]; got != "b%20c" {
+				t.Errorf("req.QueryStringParameters[%q] = %q, want %q", "q", got, "b%20c")
+			}
+			if req.MultiValueHeaders != nil {
+				t.Errorf("req.MultiValueHeaders = %v, want nil", req.MultiValueHeaders)
+			}
+			if req.MultiValueQueryStringParameters != nil {
+				t.Errorf("req.MultiValueQueryStringParameters = %v, want nil", req.MultiValueQueryStringParameters)
+			}
+
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"statusCode":404,"statusDescription":"404 Not Found","headers":{"Content-Type":"text/plain"},"body":"not found"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar?q=a&q=b%20c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("X-Foo", "bar1")
+	req.Header.Add("X-Foo", "bar2")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if resp.Status != "404 Not Found" {
+		t.Errorf("resp.Status = %q, want %q", resp.Status, "404 Not Found")
+	}
+	if resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", resp.Header.Get("Content-Type"), "text/plain")
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "not found" {
+		t.Errorf("body = %q, want %q", string(body), "not found")
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBufferedTransport_ALBMultiValueHeaders(t *testing.T) {
+	transport := &BufferedTransport{
+		Codec: EventFormatALBMultiValueHeaders,
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req requestALB
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if got, want := req.MultiValueHeaders["x-foo"], []string{"bar1", "bar2"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("req.MultiValueHeaders[%q] = %q, want %q", "x-foo", got, want)
+			}
+			if got, want := req.MultiValueQueryStringParameters["q"], []string{"a", "b%20c"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("req.MultiValueQueryStringParameters[%q] = %q, want %q", "q", got, want)
+			}
+			if req.Headers != nil {
+				t.Errorf("req.Headers = %v, want nil", req.Headers)
+			}
+			if req.QueryStringParameters != nil {
+				t.Errorf("req.QueryStringParameters = %v, want nil", req.QueryStringParameters)
+			}
+
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"statusCode":200,"statusDescription":"200 OK","multiValueHeaders":{"Set-Cookie":["a=1","b=2"]},"body":"ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar?q=a&q=b%20c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("X-Foo", "bar1")
+	req.Header.Add("X-Foo", "bar2")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Status != "200 OK" {
+		t.Errorf("resp.Status = %q, want %q", resp.Status, "200 OK")
+	}
+	if got, want := resp.Header.Values("Set-Cookie"), []string{"a=1", "b=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resp.Header.Values(%q) = %q, want %q", "Set-Cookie", got, want)
+	}
+}
+
+func TestParseRawQuery(t *testing.T) {
+	got := parseRawQuery("a=1&b=%20&&c&a=2")
+	want := [][2]string{{"a", "1"}, {"b", "%20"}, {"c", ""}, {"a", "2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRawQuery() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package lambtrip
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestV1 is the payload format version 1.0 of Amazon API Gateway.
+type requestV1 struct {
+	Version                         string              `json:"version"`
+	Resource                        string              `json:"resource"`
+	Path                            string              `json:"path"`
+	HTTPMethod                      string              `json:"httpMethod"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	PathParameters                  map[string]string   `json:"pathParameters"`
+	StageVariables                  map[string]string   `json:"stageVariables"`
+	RequestContext                  *requestContextV1   `json:"requestContext"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+}
+
+type requestContextV1 struct {
+	DomainName       string                    `json:"domainName,omitempty"`
+	DomainPrefix     string                    `json:"domainPrefix,omitempty"`
+	ResourcePath     string                    `json:"resourcePath"`
+	HTTPMethod       string                    `json:"httpMethod"`
+	Path             string                    `json:"path"`
+	Protocol         string                    `json:"protocol,omitempty"`
+	Stage            string                    `json:"stage,omitempty"`
+	RequestID        string                    `json:"requestId,omitempty"`
+	RequestTime      string                    `json:"requestTime,omitempty"`
+	RequestTimeEpoch int64                     `json:"requestTimeEpoch,omitempty"`
+	Identity         *requestContextV1Identity `json:"identity"`
+}
+
+type requestContextV1Identity struct {
+	SourceIP  string `json:"sourceIp,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+func buildRequestV1(req *http.Request) (*requestV1, error) {
+	now := time.Now().UTC()
+
+	// build the body
+	body, isBase64Encoded, err := buildBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// build the headers
+	// API Gateway uses the last value of the header for single-value headers.
+	var headers map[string]string
+	var multiValueHeaders map[string][]string
+	if len(req.Header) > 0 {
+		headers = make(map[string]string, len(req.Header))
+		multiValueHeaders = make(map[string][]string, len(req.Header))
+		for k, v := range req.Header {
+			if len(v) == 0 {
+				continue
+			}
+			headers[k] = v[len(v)-1]
+			multiValueHeaders[k] = v
+		}
+	}
+
+	// build the query string parameters
+	var query map[string]string
+	var multiValueQuery map[string][]string
+	if q := req.URL.Query(); len(q) > 0 {
+		query = make(map[string]string, len(q))
+		multiValueQuery = make(map[string][]string, len(q))
+		for k, v := range q {
+			query[k] = v[len(v)-1]
+			multiValueQuery[k] = v
+		}
+	}
+
+	// build the path parameters
+	// the function is integrated with the greedy path variable "/{proxy+}".
+	var pathParameters map[string]string
+	if proxy := strings.TrimPrefix(req.URL.Path, "/"); proxy != "" {
+		pathParameters = map[string]string{
+			"proxy": proxy,
+		}
+	}
+
+	id, err := newRequestID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &requestV1{
+		Version:                         "1.0",
+		Resource:                        "/{proxy+}",
+		Path:                            req.URL.Path,
+		HTTPMethod:                      req.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               multiValueHeaders,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: multiValueQuery,
+		PathParameters:                  pathParameters,
+		RequestContext: &requestContextV1{
+			DomainName:       requestHost(req),
+			DomainPrefix:     domainPrefix(requestHost(req)),
+			ResourcePath:     "/{proxy+}",
+			HTTPMethod:       req.Method,
+			Path:             req.URL.Path,
+			Protocol:         requestProtocol(req),
+			Stage:            "$default",
+			RequestID:        id,
+			RequestTime:      now.Format(timeFormat),
+			RequestTimeEpoch: now.UnixMilli(),
+			Identity: &requestContextV1Identity{
+				SourceIP:  sourceIP(req),
+				UserAgent: req.UserAgent(),
+			},
+		},
+		Body:            body,
+		IsBase64Encoded: isBase64Encoded,
+	}, nil
+}
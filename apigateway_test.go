@@ -0,0 +1,108 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestBufferedTransport_APIGatewayV1(t *testing.T) {
+	transport := &BufferedTransport{
+		Codec: EventFormatAPIGatewayV1,
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req requestV1
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if req.Version != "1.0" {
+				t.Errorf("req.Version = %q, want %q", req.Version, "1.0")
+			}
+			if req.Resource != "/{proxy+}" {
+				t.Errorf("req.Resource = %q, want %q", req.Resource, "/{proxy+}")
+			}
+			if req.HTTPMethod != http.MethodPost {
+				t.Errorf("req.HTTPMethod = %q, want %q", req.HTTPMethod, http.MethodPost)
+			}
+			if req.Path != "/foo/bar" {
+				t.Errorf("req.Path = %q, want %q", req.Path, "/foo/bar")
+			}
+			if req.Body != `{"hello":"world"}` {
+				t.Errorf("req.Body = %q, want %q", req.Body, `{"hello":"world"}`)
+			}
+			if req.IsBase64Encoded {
+				t.Errorf("req.IsBase64Encoded = %v, want %v", req.IsBase64Encoded, false)
+			}
+			if got := req.Headers["X-Foo"]; got != "bar2" {
+				t.Errorf("req.Headers[%q] = %q, want %q", "X-Foo", got, "bar2")
+			}
+			if got, want := req.MultiValueHeaders["X-Foo"], []string{"bar1", "bar2"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("req.MultiValueHeaders[%q] = %q, want %q", "X-Foo", got, want)
+			}
+			if got := req.QueryStringParameters["q"]; got != "b c" {
+				t.Errorf("req.QueryStringParameters[%q] = %q, want %q", "q", got, "b c")
+			}
+			if got, want := req.MultiValueQueryStringParameters["q"], []string{"a", "b c"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("req.MultiValueQueryStringParameters[%q] = %q, want %q", "q", got, want)
+			}
+			if got := req.PathParameters["proxy"]; got != "foo/bar" {
+				t.Errorf("req.PathParameters[%q] = %q, want %q", "proxy", got, "foo/bar")
+			}
+			if req.RequestContext.HTTPMethod != http.MethodPost {
+				t.Errorf("req.RequestContext.HTTPMethod = %q, want %q", req.RequestContext.HTTPMethod, http.MethodPost)
+			}
+			if req.RequestContext.RequestID == "" {
+				t.Errorf("req.RequestContext.RequestID = %q, want non-empty", req.RequestContext.RequestID)
+			}
+			if req.RequestContext.Identity.UserAgent != "lambtrip-test" {
+				t.Errorf("req.RequestContext.Identity.UserAgent = %q, want %q", req.RequestContext.Identity.UserAgent, "lambtrip-test")
+			}
+
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"statusCode":201,"headers":{"X-Single":"a","X-Multi":"b"},"multiValueHeaders":{"X-Multi":["a","b"]},"body":"\"Hello, world!\""}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	body := `{"hello":"world"}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "lambda://function-name/foo/bar?q=a&q=b+c", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lambtrip-test")
+	req.Header.Add("X-Foo", "bar1")
+	req.Header.Add("X-Foo", "bar2")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if got, want := resp.Header.Values("X-Single"), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resp.Header.Values(%q) = %q, want %q", "X-Single", got, want)
+	}
+	if got, want := resp.Header.Values("X-Multi"), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resp.Header.Values(%q) = %q, want %q", "X-Multi", got, want)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(respBody) != `"Hello, world!"` {
+		t.Errorf("body = %q, want %q", string(respBody), `"Hello, world!"`)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
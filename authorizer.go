@@ -0,0 +1,87 @@
+package lambtrip
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrUnauthorized is the error that rejects the request.
+// If AuthorizerFunc of the transports returns an error wrapping it,
+// the transports return 401 Unauthorized without invoking the function.
+var ErrUnauthorized = errors.New("lambtrip: unauthorized")
+
+// Authorizer is the authorizer context of the request.
+// It is sent as requestContext.authorizer of the events in the payload format version 2.0.
+type Authorizer struct {
+	// JWT is the context of JWT authorizers.
+	JWT *JWTAuthorizer `json:"jwt,omitempty"`
+
+	// Lambda is the context returned by Lambda authorizers.
+	Lambda map[string]any `json:"lambda,omitempty"`
+
+	// IAM is the context of the IAM authorizer.
+	IAM *IAMAuthorizer `json:"iam,omitempty"`
+}
+
+// JWTAuthorizer is the context of JWT authorizers.
+type JWTAuthorizer struct {
+	// Claims is the claims of the token.
+	// Non-string values are encoded in JSON.
+	Claims map[string]string `json:"claims"`
+
+	// Scopes is the scopes of the token.
+	Scopes []string `json:"scopes"`
+}
+
+type authorizerKey struct{}
+
+// WithAuthorizer returns a copy of ctx with the authorizer context.
+// The transports send it to the function when they send requests with the returned context.
+func WithAuthorizer(ctx context.Context, a *Authorizer) context.Context {
+	return context.WithValue(ctx, authorizerKey{}, a)
+}
+
+// AuthorizerFromContext returns the authorizer context attached by WithAuthorizer.
+func AuthorizerFromContext(ctx context.Context) (*Authorizer, bool) {
+	a, ok := ctx.Value(authorizerKey{}).(*Authorizer)
+	return a, ok && a != nil
+}
+
+// authorize returns a shallow copy of req with the authorizer context returned by fn.
+func authorize(fn func(req *http.Request) (*Authorizer, error), req *http.Request) (*http.Request, error) {
+	if fn == nil {
+		return req, nil
+	}
+	a, err := fn(req)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return req, nil
+	}
+	return req.WithContext(WithAuthorizer(req.Context(), a)), nil
+}
+
+// newUnauthorizedResponse builds a response that API Gateway HTTP APIs return when the authorizer rejects the request.
+func newUnauthorizedResponse(req *http.Request) *http.Response {
+	body := `{"message":"Unauthorized"}`
+	h := make(http.Header)
+	h.Set("Content-Type", "application/json")
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		Status:        "401 Unauthorized",
+		StatusCode:    http.StatusUnauthorized,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		ProtoMinor:    0,
+		Request:       req,
+		Header:        h,
+		ContentLength: int64(len(body)),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		Close:         true,
+	}
+}
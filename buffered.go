@@ -3,19 +3,23 @@ package lambtrip
 import (
 	"bytes"
 	"context"
-	"crypto/rand"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/shogo82148/lambtrip/internal/lambdahandler"
 )
 
 // LambdaError is an error returned by the lambda client.
@@ -28,33 +32,143 @@ func (e *LambdaError) Error() string {
 	return fmt.Sprintf("lambtrip: unexpected status code %d", e.StatusCode)
 }
 
+// FunctionError is an error returned by the lambda function.
+// It is returned when the function throws an error, times out, or crashes.
+type FunctionError struct {
+	// FunctionError is the value of the X-Amz-Function-Error header.
+	// e.g. "Unhandled"
+	FunctionError string
+
+	// ErrorMessage is the error message reported by the runtime.
+	ErrorMessage string
+
+	// ErrorType is the error type reported by the runtime.
+	// e.g. "Error", "Runtime.ExitError", "Sandbox.Timedout"
+	ErrorType string
+
+	// StackTrace is the stack trace reported by the runtime.
+	StackTrace []string
+
+	// ExecutedVersion is the version of the function that executed.
+	ExecutedVersion string
+
+	// LogResult is the last 4 KB of the execution log.
+	// It is available only if the transport requests the execution log with types.LogTypeTail.
+	LogResult string
+
+	// Payload is the raw payload returned by the lambda function.
+	Payload []byte
+}
+
+func (e *FunctionError) Error() string {
+	if e.ErrorType == "" && e.ErrorMessage == "" {
+		return fmt.Sprintf("lambtrip: function error: %s", e.FunctionError)
+	}
+	return fmt.Sprintf("lambtrip: function error: %s: %s", e.ErrorType, e.ErrorMessage)
+}
+
+func newFunctionError(out *lambda.InvokeOutput) *FunctionError {
+	e := &FunctionError{
+		FunctionError:   aws.ToString(out.FunctionError),
+		ExecutedVersion: aws.ToString(out.ExecutedVersion),
+		Payload:         out.Payload,
+	}
+
+	if out.LogResult != nil {
+		e.LogResult = decodeLogResult(*out.LogResult)
+	}
+
+	var payload struct {
+		ErrorMessage string            `json:"errorMessage"`
+		ErrorType    string            `json:"errorType"`
+		StackTrace   []json.RawMessage `json:"stackTrace"`
+	}
+	if err := json.Unmarshal(out.Payload, &payload); err != nil {
+		// the payload is not a JSON object; keep it as is.
+		return e
+	}
+	e.ErrorMessage = payload.ErrorMessage
+	e.ErrorType = payload.ErrorType
+	for _, frame := range payload.StackTrace {
+		e.StackTrace = append(e.StackTrace, stackFrame(frame))
+	}
+	return e
+}
+
+// decodeLogResult decodes the base64-encoded execution log.
+func decodeLogResult(logResult string) string {
+	decoded, err := base64.StdEncoding.DecodeString(logResult)
+	if err != nil {
+		return logResult
+	}
+	return string(decoded)
+}
+
+// stackFrame formats a frame of the stack trace.
+// Most runtimes report frames as strings,
+// but the Go runtime reports them as objects such as {"path": "...", "line": 42, "label": "..."}.
+func stackFrame(frame json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(frame, &s); err == nil {
+		return s
+	}
+
+	var f struct {
+		Path  string `json:"path"`
+		Line  int    `json:"line"`
+		Label string `json:"label"`
+	}
+	if err := json.Unmarshal(frame, &f); err == nil && f.Path != "" {
+		return fmt.Sprintf("%s (%s:%d)", f.Label, f.Path, f.Line)
+	}
+	return string(frame)
+}
+
 const timeFormat = "02/Jan/2006:15:04:05 -0700"
 
-var _ invokeAPIClient = (*lambda.Client)(nil)
+// invocationTypeHeader is the header to specify the invocation type of the request.
+const invocationTypeHeader = "X-Amz-Invocation-Type"
+
+// logResultHeader is the header that contains the base64-encoded execution log.
+const logResultHeader = "X-Amz-Log-Result"
+
+var _ InvokeAPIClient = (*lambda.Client)(nil)
 
-type invokeAPIClient interface {
+// InvokeAPIClient is a client for the Invoke API of AWS Lambda.
+// *lambda.Client implements it.
+// It can be implemented by wrappers of the client, e.g. for caching, metrics, or fakes.
+type InvokeAPIClient interface {
 	Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error)
 }
 
 type request struct {
-	Version         string            `json:"version"`
-	RouteKey        string            `json:"routeKey"`
-	HTTPMethod      string            `json:"httpMethod"`
-	Body            string            `json:"body"`
-	IsBase64Encoded bool              `json:"isBase64Encoded"`
-	RawPath         string            `json:"rawPath"`
-	RawQueryString  string            `json:"rawQueryString"`
-	Headers         map[string]string `json:"headers"`
-	Cookies         []string          `json:"cookies"`
-	RequestContext  *requestContext   `json:"requestContext"`
+	Version               string            `json:"version"`
+	RouteKey              string            `json:"routeKey"`
+	HTTPMethod            string            `json:"httpMethod"`
+	Body                  string            `json:"body"`
+	IsBase64Encoded       bool              `json:"isBase64Encoded"`
+	RawPath               string            `json:"rawPath"`
+	RawQueryString        string            `json:"rawQueryString"`
+	Headers               map[string]string `json:"headers"`
+	QueryStringParameters map[string]string `json:"queryStringParameters,omitempty"`
+	PathParameters        map[string]string `json:"pathParameters,omitempty"`
+	StageVariables        map[string]string `json:"stageVariables,omitempty"`
+	Cookies               []string          `json:"cookies"`
+	RequestContext        *requestContext   `json:"requestContext"`
 }
 
 type requestContext struct {
-	HTTP      *requestContextHTTP `json:"http"`
-	RequestID string              `json:"requestId,omitempty"`
-	Stage     string              `json:"stage,omitempty"`
-	Time      string              `json:"time,omitempty"`
-	TimeEpoch int64               `json:"timeEpoch,omitempty"`
+	AccountID    string              `json:"accountId,omitempty"`
+	APIID        string              `json:"apiId,omitempty"`
+	Authorizer   *Authorizer         `json:"authorizer,omitempty"`
+	DomainName   string              `json:"domainName,omitempty"`
+	DomainPrefix string              `json:"domainPrefix,omitempty"`
+	HTTP         *requestContextHTTP `json:"http"`
+	RequestID    string              `json:"requestId,omitempty"`
+	RouteKey     string              `json:"routeKey,omitempty"`
+	Stage        string              `json:"stage,omitempty"`
+	Time         string              `json:"time,omitempty"`
+	TimeEpoch    int64               `json:"timeEpoch,omitempty"`
 }
 
 type requestContextHTTP struct {
@@ -66,20 +180,32 @@ type requestContextHTTP struct {
 }
 
 type response struct {
-	StatusCode      int               `json:"statusCode"`
-	Headers         map[string]string `json:"headers"`
-	Body            string            `json:"body"`
-	IsBase64Encoded bool              `json:"isBase64Encoded"`
-	Cookies         []string          `json:"cookies"`
+	StatusCode        int                 `json:"statusCode"`
+	StatusDescription string              `json:"statusDescription"`
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+	Cookies           []string            `json:"cookies"`
 }
 
 func (r *response) status() string {
 	statusCode := r.statusCode()
+	code := strconv.Itoa(statusCode)
+
+	// ALB targets return the status description such as "200 OK".
+	if desc := r.StatusDescription; desc != "" {
+		if strings.HasPrefix(desc, code+" ") {
+			return desc
+		}
+		return code + " " + desc
+	}
+
 	text := http.StatusText(statusCode)
 	if text == "" {
-		return strconv.Itoa(statusCode)
+		return code
 	}
-	return strconv.Itoa(statusCode) + " " + text
+	return code + " " + text
 }
 
 func (r *response) statusCode() int {
@@ -89,10 +215,23 @@ func (r *response) statusCode() int {
 	return r.StatusCode
 }
 
-func (r *response) header() http.Header {
-	h := make(http.Header, len(r.Headers)+len(r.Cookies))
+func (r *response) header(defaultContentType string) http.Header {
+	h := make(http.Header, len(r.Headers)+len(r.MultiValueHeaders)+len(r.Cookies))
+	for k, v := range r.MultiValueHeaders {
+		for _, vv := range v {
+			h.Add(k, vv)
+		}
+	}
+
+	// if the same key-value pair is specified in both headers and multiValueHeaders,
+	// only the values from multiValueHeaders will appear.
+	// the comma-separated values of list-based headers are split into the elements.
 	for k, v := range r.Headers {
-		h.Set(k, v)
+		for _, vv := range splitHeaderValues(k, v) {
+			if !containsValue(h.Values(k), vv) {
+				h.Add(k, vv)
+			}
+		}
 	}
 
 	for _, c := range r.Cookies {
@@ -100,11 +239,20 @@ func (r *response) header() http.Header {
 	}
 
 	if ct := h.Get("Content-Type"); ct == "" {
-		h.Set("Content-Type", "application/json")
+		h.Set("Content-Type", defaultContentType)
 	}
 	return h
 }
 
+func containsValue(values []string, v string) bool {
+	for _, vv := range values {
+		if vv == v {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *response) body() (body io.ReadCloser, contentLength int64, err error) {
 	if r.IsBase64Encoded {
 		decoded, err := base64.StdEncoding.DecodeString(r.Body)
@@ -122,12 +270,110 @@ func (r *response) body() (body io.ReadCloser, contentLength int64, err error) {
 var _ http.RoundTripper = (*BufferedTransport)(nil)
 
 type BufferedTransport struct {
-	lambda invokeAPIClient
+	// ErrorResponse, if true, makes RoundTrip return a 502 Bad Gateway response instead of an error
+	// when the function fails, times out, or returns a malformed payload,
+	// in the same way as Lambda Function URLs.
+	ErrorResponse bool
+
+	// Codec converts requests into events and payloads into responses.
+	// If nil, EventFormatFunctionURL is used.
+	Codec EventCodec
+
+	// InvocationType is the default invocation type.
+	// If it is types.InvocationTypeEvent, RoundTrip invokes the function asynchronously,
+	// and returns 202 Accepted without waiting for the function.
+	// The zero value means types.InvocationTypeRequestResponse.
+	InvocationType types.InvocationType
+
+	// InvocationTypeHeader, if true, makes the X-Amz-Invocation-Type header of the request override InvocationType.
+	// The header is removed from the event.
+	// If false, the header is passed to the function as is, in the same way as Lambda Function URLs.
+	// Enable it only if the clients are trusted to choose asynchronous invocations.
+	InvocationTypeHeader bool
+
+	// LogType is the type of the execution log to request.
+	// If it is types.LogTypeTail, the last 4 KB of the execution log is returned
+	// in the X-Amz-Log-Result header of the response as a base64-encoded string,
+	// and in the LogResult field of FunctionError.
+	// It is ignored in asynchronous invocations.
+	LogType types.LogType
+
+	// ClientContextFunc returns the client context passed to the function.
+	// If nil, the client context attached to the request context by WithClientContext is used.
+	ClientContextFunc func(req *http.Request) (*ClientContext, error)
+
+	// TrustedProxies is the list of networks of the trusted proxies.
+	// If the peer of the request is in them, the source IP address of the event is taken from
+	// the Forwarded header or the X-Forwarded-For header instead of RemoteAddr of the request.
+	TrustedProxies []netip.Prefix
+
+	// AuthorizerFunc returns the authorizer context sent to the function.
+	// If it returns an error wrapping ErrUnauthorized, RoundTrip returns 401 Unauthorized
+	// without invoking the function.
+	// If nil, the authorizer context attached to the request context by WithAuthorizer is used.
+	AuthorizerFunc func(req *http.Request) (*Authorizer, error)
+
+	// BinaryDetector reports whether the request body is binary by the headers.
+	// The binary bodies are sent in base64.
+	// If nil, the bodies are binary unless they have text types, such as text/* and application/json,
+	// and no Content-Encoding.
+	BinaryDetector func(h http.Header) bool
+
+	// DefaultContentType is the Content-Type of the responses that don't specify it.
+	// If empty, "application/json" is used.
+	DefaultContentType string
+
+	// Protocol is the protocol sent in the event, such as "HTTP/1.1".
+	// If empty, the protocol of the request is used.
+	Protocol string
+
+	// QualifierFunc returns the function qualifier of the request.
+	// If nil, the user information of the URL is used, e.g. lambda://alias@function-name/foo/bar.
+	QualifierFunc func(req *http.Request) (*string, error)
+
+	// Retry is the policy to retry invocations that fail with throttling or transient errors.
+	// If nil, RoundTrip doesn't retry.
+	Retry *RetryPolicy
+
+	// Limiter caps the number of in-flight invocations per function name and qualifier.
+	// If nil, the number of invocations is not limited.
+	Limiter *ConcurrencyLimiter
+
+	// CircuitBreaker stops invoking the functions that are persistently failing.
+	// If nil, the functions are always invoked.
+	CircuitBreaker *CircuitBreaker
+
+	lambda InvokeAPIClient
 }
 
-func NewBufferedTransport(c *lambda.Client) *BufferedTransport {
+// NewBufferedTransport returns a new BufferedTransport that invokes the functions with c.
+func NewBufferedTransport(c InvokeAPIClient, opts ...Option) *BufferedTransport {
+	o := newOptions(opts)
 	return &BufferedTransport{
-		lambda: c,
+		ErrorResponse:        o.errorResponse,
+		Codec:                o.codec,
+		InvocationType:       o.invocationType,
+		InvocationTypeHeader: o.invocationTypeHeader,
+		LogType:              o.logType,
+		ClientContextFunc:    o.clientContextFunc,
+		TrustedProxies:       o.trustedProxies,
+		AuthorizerFunc:       o.authorizerFunc,
+		BinaryDetector:       o.binaryDetector,
+		DefaultContentType:   o.defaultContentType,
+		Protocol:             o.protocol,
+		QualifierFunc:        o.qualifierFunc,
+		Retry:                o.retry,
+		Limiter:              o.limiter,
+		CircuitBreaker:       o.circuitBreaker,
+		lambda:               c,
+	}
+}
+
+func (t *BufferedTransport) eventOptions() eventOptions {
+	return eventOptions{
+		binaryDetector:     t.BinaryDetector,
+		defaultContentType: t.DefaultContentType,
+		protocol:           t.Protocol,
 	}
 }
 
@@ -135,70 +381,233 @@ func (t *BufferedTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	ctx := req.Context()
 
 	// build the request
-	r, err := buildRequest(req)
+	invocationType, eventReq, err := t.invocationType(req)
 	if err != nil {
 		return nil, err
 	}
-	payload, err := json.Marshal(r)
+	eventReq = withSourceIP(eventReq, t.TrustedProxies)
+	eventReq = withEventOptions(eventReq, t.eventOptions())
+	eventReq, err = authorize(t.AuthorizerFunc, eventReq)
+	if errors.Is(err, ErrUnauthorized) {
+		return newUnauthorizedResponse(req), nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	if err := checkContentLength(eventReq, invocationType); err != nil {
+		return nil, err
+	}
+	codec := codecOrDefault(t.Codec)
+	payload, err := codec.EncodeRequest(eventReq)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPayloadSize(payload, invocationType); err != nil {
+		return nil, err
+	}
 
 	// invoke the lambda
+	qualifier, err := requestQualifier(t.QualifierFunc, req)
+	if err != nil {
+		return nil, err
+	}
+	clientContext, err := encodeClientContext(t.ClientContextFunc, req)
+	if err != nil {
+		return nil, err
+	}
 	in := &lambda.InvokeInput{
-		FunctionName: aws.String(req.URL.Host),
-		Payload:      payload,
+		FunctionName:   aws.String(req.URL.Host),
+		Qualifier:      qualifier,
+		InvocationType: invocationType,
+		ClientContext:  clientContext,
+		Payload:        payload,
+	}
+	if invocationType != types.InvocationTypeEvent {
+		in.LogType = t.LogType
+	}
+	done, err := t.CircuitBreaker.allow(req.URL.Host, qualifier)
+	if err != nil {
+		return nil, err
+	}
+	var out *lambda.InvokeOutput
+	err = t.Retry.retry(req, func() error {
+		release, err := t.Limiter.acquire(ctx, req.URL.Host, qualifier)
+		if err != nil {
+			return err
+		}
+		defer release()
+		out, err = t.lambda.Invoke(ctx, in)
+		return err
+	})
+	if err != nil {
+		done(nil, err)
+		return nil, err
 	}
-	if req.URL.User != nil {
-		// lambda://alias@function
-		in.Qualifier = aws.String(req.URL.User.Username())
+
+	if invocationType == types.InvocationTypeEvent {
+		resp, err := handleAsyncInvokeOutput(out, req)
+		done(resp, err)
+		return resp, err
 	}
-	out, err := t.lambda.Invoke(ctx, in)
+
+	resp, err := handleInvokeOutput(codec, out, req, t.eventOptions())
+	done(resp, err)
 	if err != nil {
+		if t.ErrorResponse {
+			requestID, _ := awsmiddleware.GetRequestIDMetadata(out.ResultMetadata)
+			resp := newErrorResponse(req, requestID, err)
+			setLogResult(resp, out)
+			return resp, nil
+		}
 		return nil, err
 	}
+	return resp, nil
+}
 
+// invocationType returns the invocation type for req.
+// If InvocationTypeHeader is true, the X-Amz-Invocation-Type header overrides the InvocationType of the transport.
+// If req has the header, it returns a shallow copy of req without the header
+// so that the function doesn't receive it.
+func (t *BufferedTransport) invocationType(req *http.Request) (types.InvocationType, *http.Request, error) {
+	if !t.InvocationTypeHeader {
+		return t.InvocationType, req, nil
+	}
+	v := req.Header.Get(invocationTypeHeader)
+	if v == "" {
+		return t.InvocationType, req, nil
+	}
+
+	invocationType := types.InvocationType(v)
+	switch invocationType {
+	case types.InvocationTypeEvent, types.InvocationTypeRequestResponse:
+	default:
+		return "", nil, fmt.Errorf("lambtrip: unsupported invocation type: %q", v)
+	}
+
+	r := new(http.Request)
+	*r = *req
+	r.Header = req.Header.Clone()
+	r.Header.Del(invocationTypeHeader)
+	return invocationType, r, nil
+}
+
+// handleAsyncInvokeOutput builds the response for the asynchronous invocation.
+// Lambda queues the event and returns 202 Accepted without waiting for the function.
+// Retries and destinations are handled by Lambda according to the asynchronous invocation
+// configuration of the function.
+func handleAsyncInvokeOutput(out *lambda.InvokeOutput, req *http.Request) (*http.Response, error) {
+	if out.StatusCode != http.StatusAccepted {
+		return nil, &LambdaError{
+			StatusCode: int(out.StatusCode),
+			Payload:    out.Payload,
+		}
+	}
+
+	h := make(http.Header)
+	h.Set("Content-Length", "0")
+	if requestID, ok := awsmiddleware.GetRequestIDMetadata(out.ResultMetadata); ok {
+		h.Set("x-amzn-RequestId", requestID)
+	}
+	return &http.Response{
+		Status:        "202 Accepted",
+		StatusCode:    http.StatusAccepted,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		ProtoMinor:    0,
+		Request:       req,
+		Header:        h,
+		ContentLength: 0,
+		Body:          http.NoBody,
+		Close:         true,
+	}, nil
+}
+
+// handleInvokeOutput builds the response for the synchronous invocation.
+// o is passed to the codec, but the response refers to req itself.
+func handleInvokeOutput(codec EventCodec, out *lambda.InvokeOutput, req *http.Request, o eventOptions) (*http.Response, error) {
 	if out.StatusCode != http.StatusOK {
 		return nil, &LambdaError{
 			StatusCode: int(out.StatusCode),
 			Payload:    out.Payload,
 		}
 	}
+	if out.FunctionError != nil {
+		return nil, newFunctionError(out)
+	}
 
 	// build the response
-	var resp response
-	if err := json.Unmarshal(out.Payload, &resp); err != nil {
+	resp, err := codec.DecodeResponse(out.Payload, withEventOptions(req, o))
+	if err != nil {
 		return nil, err
 	}
-	return buildResponse(&resp, req)
+	resp.Request = req
+	setLogResult(resp, out)
+	return resp, nil
+}
+
+// setLogResult sets the execution log to the X-Amz-Log-Result header of resp.
+func setLogResult(resp *http.Response, out *lambda.InvokeOutput) {
+	if out.LogResult == nil {
+		return
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set(logResultHeader, *out.LogResult)
+}
+
+// functionQualifier returns the function qualifier specified by the URL.
+// e.g. lambda://alias@function-name/foo/bar
+// It returns nil if the URL has no qualifier.
+func functionQualifier(u *url.URL) (*string, error) {
+	if u.User == nil {
+		return nil, nil
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("lambtrip: password is not allowed in the function qualifier: %s", u.Redacted())
+	}
+	qualifier := u.User.Username()
+	if !isValidQualifier(qualifier) {
+		return nil, fmt.Errorf("lambtrip: invalid function qualifier: %q", qualifier)
+	}
+	return aws.String(qualifier), nil
+}
+
+// isValidQualifier reports whether s is a valid function qualifier.
+// The qualifier is a version number (including $LATEST) or an alias name.
+// It must match the pattern (|[a-zA-Z0-9$_-]+) and be 1-128 characters long.
+func isValidQualifier(s string) bool {
+	if len(s) == 0 || len(s) > 128 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+		case 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9':
+		case c == '$' || c == '_' || c == '-':
+		default:
+			return false
+		}
+	}
+	return true
 }
 
-func buildRequest(req *http.Request) (*request, error) {
+func buildRequest(req *http.Request, c FunctionURLCodec) (*request, error) {
 	now := time.Now().UTC()
 
 	// build the body
-	isBase64Encoded := req.Body != nil && isBinary(req.Header)
-	body := []byte{}
-	if req.Body != nil {
-		var err error
-		body, err = io.ReadAll(req.Body)
-		if err != nil {
-			return nil, err
-		}
-		if isBase64Encoded {
-			buf := make([]byte, base64.StdEncoding.EncodedLen(len(body)))
-			base64.StdEncoding.Encode(buf, body)
-			body = buf
-		}
+	body, isBase64Encoded, err := buildBody(req)
+	if err != nil {
+		return nil, err
 	}
 
 	// build the headers
-	headers := make(map[string]string, len(req.Header))
-	for k, v := range req.Header {
-		if strings.EqualFold(k, "Cookie") {
-			continue
-		}
-		headers[k] = strings.Join(v, ",")
+	headers := buildHeaders(req.Header)
+	domainName := c.domainName(req)
+	if domainName != "" {
+		headers["host"] = domainName
 	}
 
 	// build the cookies
@@ -212,22 +621,35 @@ func buildRequest(req *http.Request) (*request, error) {
 		return nil, err
 	}
 
+	routeKey, pathParameters := matchRoute(c.Routes, req)
+
 	return &request{
-		Version:         "2.0",
-		RouteKey:        "$default",
-		HTTPMethod:      req.Method,
-		Body:            string(body),
-		IsBase64Encoded: isBase64Encoded,
-		RawPath:         req.URL.EscapedPath(),
-		RawQueryString:  req.URL.RawQuery,
-		Headers:         headers,
-		Cookies:         cookies,
+		Version:               "2.0",
+		RouteKey:              routeKey,
+		HTTPMethod:            req.Method,
+		Body:                  body,
+		IsBase64Encoded:       isBase64Encoded,
+		RawPath:               req.URL.EscapedPath(),
+		RawQueryString:        req.URL.RawQuery,
+		Headers:               headers,
+		QueryStringParameters: buildQueryStringParameters(req.URL.RawQuery),
+		PathParameters:        pathParameters,
+		StageVariables:        c.StageVariables,
+		Cookies:               cookies,
 		RequestContext: &requestContext{
-			RequestID: id,
+			AccountID:    c.accountID(),
+			APIID:        c.urlID(domainName),
+			Authorizer:   c.authorizer(req),
+			DomainName:   domainName,
+			DomainPrefix: domainPrefix(domainName),
+			RequestID:    id,
+			RouteKey:     routeKey,
+			Stage:        c.stage(),
 			HTTP: &requestContextHTTP{
 				Method:    req.Method,
 				Path:      req.URL.Path,
-				Protocol:  "HTTP/1.0",
+				Protocol:  requestProtocol(req),
+				SourceIP:  sourceIP(req),
 				UserAgent: req.UserAgent(),
 			},
 			Time:      now.Format(timeFormat),
@@ -236,6 +658,21 @@ func buildRequest(req *http.Request) (*request, error) {
 	}, nil
 }
 
+// buildBody reads the request body and encodes it in base64 if it is binary.
+func buildBody(req *http.Request) (body string, isBase64Encoded bool, err error) {
+	if req.Body == nil {
+		return "", false, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", false, err
+	}
+	if isBinaryRequest(req) {
+		return base64.StdEncoding.EncodeToString(data), true, nil
+	}
+	return string(data), false, nil
+}
+
 // assume text/*, application/json, application/javascript, application/xml, */*+json, */*+xml, etc. as text
 func isBinary(headers http.Header) bool {
 	contentEncoding := headers.Values("Content-Encoding")
@@ -295,7 +732,7 @@ func isBinary(headers http.Header) bool {
 }
 
 func buildResponse(resp *response, req *http.Request) (*http.Response, error) {
-	h := resp.header()
+	h := resp.header(defaultContentType(req))
 	body, length, err := resp.body()
 	if err != nil {
 		return nil, fmt.Errorf("lambtrip: failed to build response body: %w", err)
@@ -315,23 +752,54 @@ func buildResponse(resp *response, req *http.Request) (*http.Response, error) {
 	}, nil
 }
 
+// newErrorResponse builds a response that Lambda Function URLs return when the function fails.
+func newErrorResponse(req *http.Request, requestID string, err error) *http.Response {
+	body := `{"Message":"Internal Server Error"}`
+	h := make(http.Header)
+	h.Set("Content-Type", "application/json")
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	h.Set("x-amzn-ErrorType", errorType(err))
+	if requestID != "" {
+		h.Set("x-amzn-RequestId", requestID)
+	}
+	return &http.Response{
+		Status:        "502 Bad Gateway",
+		StatusCode:    http.StatusBadGateway,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		ProtoMinor:    0,
+		Request:       req,
+		Header:        h,
+		ContentLength: int64(len(body)),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		Close:         true,
+	}
+}
+
+// errorType returns the value of the x-amzn-ErrorType header for err.
+func errorType(err error) string {
+	var functionErr *FunctionError
+	if errors.As(err, &functionErr) {
+		if functionErr.ErrorType != "" {
+			return functionErr.ErrorType
+		}
+		return functionErr.FunctionError
+	}
+
+	var streamErr *ResponseStreamError
+	if errors.As(err, &streamErr) && streamErr.ErrorCode != "" {
+		return streamErr.ErrorCode
+	}
+
+	var lambdaErr *LambdaError
+	if errors.As(err, &lambdaErr) {
+		return "ServiceException"
+	}
+
+	// the function returned a malformed payload.
+	return "InvalidResponse"
+}
+
 func newRequestID() (string, error) {
-	var buf [16]byte
-	if _, err := rand.Read(buf[:]); err != nil {
-		return "", err
-	}
-	buf[6] = (buf[6] & 0x0f) | 0x40 // set version to 4
-	buf[8] = (buf[8] & 0x3f) | 0x80 // set variant to 10
-
-	var dst [36]byte
-	hex.Encode(dst[:], buf[:4])
-	dst[8] = '-'
-	hex.Encode(dst[9:], buf[4:6])
-	dst[13] = '-'
-	hex.Encode(dst[14:], buf[6:8])
-	dst[18] = '-'
-	hex.Encode(dst[19:], buf[8:10])
-	dst[23] = '-'
-	hex.Encode(dst[24:], buf[10:])
-	return string(dst[:]), nil
+	return lambdahandler.NewRequestID()
 }
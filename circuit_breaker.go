@@ -0,0 +1,209 @@
+package lambtrip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitOpenTimeout      = 30 * time.Second
+)
+
+// CircuitOpenError is an error returned when the circuit breaker of the function is open.
+// The function is not invoked.
+type CircuitOpenError struct {
+	FunctionName string
+	Qualifier    string
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.Qualifier == "" {
+		return fmt.Sprintf("lambtrip: circuit breaker is open: %s", e.FunctionName)
+	}
+	return fmt.Sprintf("lambtrip: circuit breaker is open: %s:%s", e.FunctionName, e.Qualifier)
+}
+
+// CircuitBreaker stops invoking functions that are persistently failing.
+// The circuit is keyed by the function name and qualifier.
+//
+// The circuit opens after the function fails FailureThreshold times in a row.
+// Function errors, 5xx responses, timeouts and other invocation errors are counted as failures.
+// While the circuit is open, the transports fail fast with CircuitOpenError.
+// After OpenTimeout, the circuit becomes half-open, and a single probe request is sent to the function.
+// If the probe succeeds, the circuit closes. Otherwise, it opens again.
+//
+// It can be shared between transports.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit.
+	// If zero, 5 is used.
+	FailureThreshold int
+
+	// OpenTimeout is the duration the circuit stays open before probing the function.
+	// If zero, 30 seconds is used.
+	OpenTimeout time.Duration
+
+	mu       sync.Mutex
+	circuits map[functionKey]*circuit
+	now      func() time.Time
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuit struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+
+	// generation is incremented on every state transition.
+	// The results of the invocations started in earlier generations are ignored.
+	generation int
+}
+
+// transition changes the state of the circuit, and starts a new generation.
+func (c *circuit) transition(state circuitState) {
+	c.state = state
+	c.generation++
+}
+
+// allow reports whether the function can be invoked,
+// and returns the function to record the result of the invocation.
+func (b *CircuitBreaker) allow(functionName string, qualifier *string) (done func(resp *http.Response, err error), err error) {
+	if b == nil {
+		return func(*http.Response, error) {}, nil
+	}
+	key := newFunctionKey(functionName, qualifier)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.circuits == nil {
+		b.circuits = make(map[functionKey]*circuit)
+	}
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+
+	switch c.state {
+	case circuitOpen:
+		if b.timeNow().Sub(c.openedAt) < b.openTimeout() {
+			return nil, &CircuitOpenError{
+				FunctionName: key.functionName,
+				Qualifier:    key.qualifier,
+			}
+		}
+		// send a probe request.
+		c.transition(circuitHalfOpen)
+		generation := c.generation
+		return func(resp *http.Response, err error) {
+			b.record(c, generation, resp, err)
+		}, nil
+	case circuitHalfOpen:
+		// another probe request is in flight.
+		return nil, &CircuitOpenError{
+			FunctionName: key.functionName,
+			Qualifier:    key.qualifier,
+		}
+	}
+	generation := c.generation
+	return func(resp *http.Response, err error) {
+		b.record(c, generation, resp, err)
+	}, nil
+}
+
+// record records the result of the invocation started in the generation.
+func (b *CircuitBreaker) record(c *circuit, generation int, resp *http.Response, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != c.generation {
+		// the circuit has changed its state since the invocation started.
+		return
+	}
+	probe := c.state == circuitHalfOpen
+
+	switch circuitResult(resp, err) {
+	case circuitSuccess:
+		if probe {
+			c.transition(circuitClosed)
+		}
+		c.failures = 0
+	case circuitFailure:
+		c.failures++
+		if probe || c.failures >= b.failureThreshold() {
+			c.transition(circuitOpen)
+			c.openedAt = b.timeNow()
+		}
+	case circuitIgnored:
+		if probe {
+			// the probe is inconclusive; allow another probe.
+			c.transition(circuitOpen)
+		}
+	}
+}
+
+type circuitOutcome int
+
+const (
+	circuitSuccess circuitOutcome = iota
+	circuitFailure
+	circuitIgnored
+)
+
+// circuitResult classifies the result of the invocation.
+// Throttling and errors caused by the caller, such as cancellation, are not counted.
+func circuitResult(resp *http.Response, err error) circuitOutcome {
+	if err == nil {
+		if resp != nil && resp.StatusCode >= 500 {
+			return circuitFailure
+		}
+		return circuitSuccess
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return circuitIgnored
+	}
+	var limitErr *ConcurrencyLimitError
+	if errors.As(err, &limitErr) {
+		return circuitIgnored
+	}
+	var tooManyRequests *types.TooManyRequestsException
+	if errors.As(err, &tooManyRequests) {
+		return circuitIgnored
+	}
+	return circuitFailure
+}
+
+func (b *CircuitBreaker) failureThreshold() int {
+	if b.FailureThreshold <= 0 {
+		return defaultCircuitFailureThreshold
+	}
+	return b.FailureThreshold
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout <= 0 {
+		return defaultCircuitOpenTimeout
+	}
+	return b.OpenTimeout
+}
+
+func (b *CircuitBreaker) timeNow() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
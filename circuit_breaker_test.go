@@ -0,0 +1,186 @@
+package lambtrip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestBufferedTransport_CircuitBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := &CircuitBreaker{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		now:              func() time.Time { return now },
+	}
+
+	var invocations int
+	failing := true
+	transport := &BufferedTransport{
+		CircuitBreaker: breaker,
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			invocations++
+			if failing {
+				return &lambda.InvokeOutput{
+					StatusCode:    http.StatusOK,
+					FunctionError: aws.String("Unhandled"),
+					Payload:       []byte(`{"errorMessage":"something wrong","errorType":"Error"}`),
+				}, nil
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	roundTrip := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// the circuit opens after two failures.
+	for i := 0; i < 2; i++ {
+		var functionErr *FunctionError
+		if _, err := roundTrip(); !errors.As(err, &functionErr) {
+			t.Fatalf("want FunctionError, got %v", err)
+		}
+	}
+	var openErr *CircuitOpenError
+	if _, err := roundTrip(); !errors.As(err, &openErr) {
+		t.Fatalf("want CircuitOpenError, got %v", err)
+	}
+	if invocations != 2 {
+		t.Errorf("invocations = %d, want %d", invocations, 2)
+	}
+
+	// the probe fails, and the circuit opens again.
+	now = now.Add(time.Minute)
+	var functionErr *FunctionError
+	if _, err := roundTrip(); !errors.As(err, &functionErr) {
+		t.Fatalf("want FunctionError, got %v", err)
+	}
+	if _, err := roundTrip(); !errors.As(err, &openErr) {
+		t.Fatalf("want CircuitOpenError, got %v", err)
+	}
+
+	// the probe succeeds, and the circuit closes.
+	now = now.Add(time.Minute)
+	failing = false
+	if _, err := roundTrip(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := roundTrip(); err != nil {
+		t.Fatal(err)
+	}
+	if invocations != 5 {
+		t.Errorf("invocations = %d, want %d", invocations, 5)
+	}
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := &CircuitBreaker{
+		FailureThreshold: 1,
+		now:              func() time.Time { return now },
+	}
+
+	done, err := breaker.allow("function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(nil, errors.New("failure"))
+
+	// only one probe is allowed in the half-open state.
+	now = now.Add(defaultCircuitOpenTimeout)
+	probe, err := breaker.allow("function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var openErr *CircuitOpenError
+	if _, err := breaker.allow("function-name", nil); !errors.As(err, &openErr) {
+		t.Errorf("want CircuitOpenError, got %v", err)
+	}
+
+	// other functions are not affected.
+	if _, err := breaker.allow("other-function", nil); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+
+	// a canceled probe doesn't close the circuit.
+	probe(nil, context.Canceled)
+	probe, err = breaker.allow("function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe(&http.Response{StatusCode: http.StatusOK}, nil)
+	if _, err := breaker.allow("function-name", nil); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_StaleResults(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := &CircuitBreaker{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+		now:              func() time.Time { return now },
+	}
+	ok := &http.Response{StatusCode: http.StatusOK}
+	failed := &http.Response{StatusCode: http.StatusBadGateway}
+
+	// two invocations start while the circuit is closed.
+	done1, err := breaker.allow("function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done2, err := breaker.allow("function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done3, err := breaker.allow("function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first one fails, and the circuit opens.
+	done1(failed, nil)
+
+	// the second one succeeds after the circuit opened; it must not close the circuit.
+	done2(ok, nil)
+	var openErr *CircuitOpenError
+	if _, err := breaker.allow("function-name", nil); !errors.As(err, &openErr) {
+		t.Fatalf("want CircuitOpenError, got %v", err)
+	}
+
+	// a probe starts.
+	now = now.Add(time.Minute)
+	probe, err := breaker.allow("function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the third one fails during the probe; it must not affect the probe.
+	done3(failed, nil)
+	if _, err := breaker.allow("function-name", nil); !errors.As(err, &openErr) {
+		t.Fatalf("want CircuitOpenError, got %v", err)
+	}
+
+	// the probe succeeds, and the circuit closes.
+	probe(ok, nil)
+	if _, err := breaker.allow("function-name", nil); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
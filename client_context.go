@@ -0,0 +1,107 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// maxClientContextSize is the maximum size of the base64-encoded client context.
+const maxClientContextSize = 3583
+
+// ClientContext is information about the client application passed to the function.
+// The function can read it via lambdacontext.ClientContext in Go, context.clientContext in Node.js, and so on.
+type ClientContext struct {
+	Client ClientApplication `json:"client"`
+	Custom map[string]string `json:"custom,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+}
+
+// ClientApplication is information about the client application.
+type ClientApplication struct {
+	InstallationID string `json:"installation_id,omitempty"`
+	AppTitle       string `json:"app_title,omitempty"`
+	AppVersionCode string `json:"app_version_code,omitempty"`
+	AppPackageName string `json:"app_package_name,omitempty"`
+}
+
+type clientContextKey struct{}
+
+// WithClientContext returns a copy of ctx with the client context.
+// The transports pass it to the function when they send requests with the returned context.
+func WithClientContext(ctx context.Context, cc *ClientContext) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, cc)
+}
+
+// ClientContextFromContext returns the client context attached by WithClientContext.
+func ClientContextFromContext(ctx context.Context) (*ClientContext, bool) {
+	cc, ok := ctx.Value(clientContextKey{}).(*ClientContext)
+	return cc, ok && cc != nil
+}
+
+// ClientContextFromHeaders returns a function that builds the client context from the request headers.
+// The keys of mapping are the header names, and the values are the keys of the custom values.
+// It starts from the client context attached by WithClientContext if any.
+// It can be used as ClientContextFunc of the transports.
+func ClientContextFromHeaders(mapping map[string]string) func(req *http.Request) (*ClientContext, error) {
+	return func(req *http.Request) (*ClientContext, error) {
+		var cc ClientContext
+		v, ok := ClientContextFromContext(req.Context())
+		if ok {
+			cc = *v
+		}
+
+		found := false
+		custom := make(map[string]string, len(cc.Custom)+len(mapping))
+		for k, v := range cc.Custom {
+			custom[k] = v
+		}
+		for header, key := range mapping {
+			if v := req.Header.Get(header); v != "" {
+				custom[key] = v
+				found = true
+			}
+		}
+		if !ok && !found {
+			return nil, nil
+		}
+		if len(custom) > 0 {
+			cc.Custom = custom
+		}
+		return &cc, nil
+	}
+}
+
+// clientContext returns the client context for req.
+// If fn is nil, the client context attached to the request context is used.
+func clientContext(fn func(req *http.Request) (*ClientContext, error), req *http.Request) (*ClientContext, error) {
+	if fn != nil {
+		return fn(req)
+	}
+	cc, _ := ClientContextFromContext(req.Context())
+	return cc, nil
+}
+
+// encodeClientContext returns the base64-encoded client context for req.
+// It returns nil if the request has no client context.
+func encodeClientContext(fn func(req *http.Request) (*ClientContext, error), req *http.Request) (*string, error) {
+	cc, err := clientContext(fn, req)
+	if err != nil {
+		return nil, err
+	}
+	if cc == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(cc)
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	if len(encoded) > maxClientContextSize {
+		return nil, fmt.Errorf("lambtrip: client context is too large: %d bytes (max %d bytes)", len(encoded), maxClientContextSize)
+	}
+	return &encoded, nil
+}
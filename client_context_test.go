@@ -0,0 +1,149 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func decodeClientContext(t *testing.T, s *string) *ClientContext {
+	t.Helper()
+	if s == nil {
+		t.Fatal("client context is nil")
+	}
+	data, err := base64.StdEncoding.DecodeString(*s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cc ClientContext
+	if err := json.Unmarshal(data, &cc); err != nil {
+		t.Fatal(err)
+	}
+	return &cc
+}
+
+func TestBufferedTransport_ClientContext(t *testing.T) {
+	want := &ClientContext{
+		Client: ClientApplication{
+			InstallationID: "installation-id",
+			AppTitle:       "app-title",
+		},
+		Custom: map[string]string{"foo": "bar"},
+		Env:    map[string]string{"platform": "Android"},
+	}
+	transport := &BufferedTransport{
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			got := decodeClientContext(t, params.ClientContext)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("client context = %#v, want %#v", got, want)
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := WithClientContext(context.Background(), want)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
+
+func TestBufferedTransport_WithoutClientContext(t *testing.T) {
+	transport := &BufferedTransport{
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			if params.ClientContext != nil {
+				t.Errorf("params.ClientContext = %q, want nil", aws.ToString(params.ClientContext))
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
+
+func TestTransport_ClientContextFromHeaders(t *testing.T) {
+	transport := &ResponseStreamTransport{
+		ClientContextFunc: ClientContextFromHeaders(map[string]string{
+			"X-Tenant-Id": "tenant",
+		}),
+		lambda: func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
+			got := decodeClientContext(t, params.ClientContext)
+			want := &ClientContext{
+				Client: ClientApplication{AppTitle: "app-title"},
+				Custom: map[string]string{"foo": "bar", "tenant": "tenant-1"},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("client context = %#v, want %#v", got, want)
+			}
+			return &invokeWithResponseStreamOutput{
+				Output: &lambda.InvokeWithResponseStreamOutput{
+					StatusCode:                http.StatusOK,
+					ResponseStreamContentType: aws.String("application/vnd.awslambda.http-integration-response"),
+				},
+				StreamGetter: GetStreamMock(func() *lambda.InvokeWithResponseStreamEventStream {
+					stream := lambda.NewInvokeWithResponseStreamEventStream()
+					stream.Reader = newInvokeWithResponseStreamResponseEventReader([][]byte{
+						[]byte(`{}`),
+						{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+					})
+					return stream
+				}),
+			}, nil
+		},
+	}
+
+	ctx := WithClientContext(context.Background(), &ClientContext{
+		Client: ClientApplication{AppTitle: "app-title"},
+		Custom: map[string]string{"foo": "bar"},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Tenant-Id", "tenant-1")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
+
+func TestEncodeClientContext_TooLarge(t *testing.T) {
+	ctx := WithClientContext(context.Background(), &ClientContext{
+		Custom: map[string]string{"foo": strings.Repeat("a", maxClientContextSize)},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encodeClientContext(nil, req); err == nil {
+		t.Error("want error, got nil")
+	}
+}
@@ -2,23 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
 	httplogger "github.com/shogo82148/go-http-logger"
 	"github.com/shogo82148/lambtrip"
 )
 
 var host, port string
 var invokeMode string
+var bootstrap string
+var trustedProxies string
+var authType string
+var jwksFile, jwtIssuer, jwtAudience string
 var logHandler slog.Handler
 var logger *slog.Logger
 
@@ -26,6 +37,12 @@ func init() {
 	flag.StringVar(&host, "host", "", "host to forward requests to")
 	flag.StringVar(&port, "port", "8080", "port to listen on")
 	flag.StringVar(&invokeMode, "invoke-mode", "BUFFERED", "invoke mode (BUFFERED or RESPONSE_STREAM)")
+	flag.StringVar(&bootstrap, "bootstrap", "", "path to the function binary to run locally instead of invoking the function on AWS")
+	flag.StringVar(&authType, "auth-type", "NONE", "auth type (NONE or AWS_IAM)")
+	flag.StringVar(&jwksFile, "jwks", "", "path to the JSON Web Key Set file to verify bearer tokens")
+	flag.StringVar(&jwtIssuer, "jwt-issuer", "", "expected issuer of bearer tokens")
+	flag.StringVar(&jwtAudience, "jwt-audience", "", "comma-separated list of expected audiences of bearer tokens")
+	flag.StringVar(&trustedProxies, "trusted-proxies", "", "comma-separated list of CIDRs of trusted proxies (e.g. 10.0.0.0/8,127.0.0.1/32)")
 
 	logHandler = slog.NewJSONHandler(os.Stderr, nil)
 	logger = slog.New(logHandler)
@@ -37,27 +54,91 @@ func main() {
 
 	// parse flags
 	flag.Parse()
-	if flag.NArg() < 1 {
-		slog.ErrorContext(ctx, "function name is required")
+	functionName := flag.Arg(0)
+	if functionName == "" {
+		if bootstrap == "" {
+			slog.ErrorContext(ctx, "function name is required")
+			os.Exit(1)
+		}
+		functionName = "function"
+	}
+	proxies, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to parse trusted proxies", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
-	functionName := flag.Arg(0)
 
 	// initialize AWS SDK
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		slog.ErrorContext(ctx, "failed to load configuration", slog.String("error", err.Error()))
+	// it is not necessary if the function runs locally and the function URL has no auth.
+	var cfg aws.Config
+	if bootstrap == "" || authType != "NONE" {
+		cfg, err = config.LoadDefaultConfig(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load configuration", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	// run the function locally
+	var localRuntime *lambtrip.LocalRuntime
+	if bootstrap != "" {
+		localRuntime, err = lambtrip.NewLocalRuntime(bootstrap)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to start the runtime API", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	// emulate the auth type of the function URL
+	var codec lambtrip.FunctionURLCodec
+	switch authType {
+	case "NONE":
+	case "AWS_IAM":
+		iam, err := lambtrip.NewIAMAuthorizer(ctx, cfg)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to resolve the caller identity", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		codec.IAM = iam
+	default:
+		slog.ErrorContext(ctx, "unknown auth type", slog.String("auth-type", authType))
 		os.Exit(1)
 	}
-	svc := lambda.NewFromConfig(cfg)
+
+	// verify bearer tokens
+	var authorizer func(req *http.Request) (*lambtrip.Authorizer, error)
+	if jwksFile != "" {
+		v, err := lambtrip.NewJWTVerifier(jwksFile)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to load JWKS", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		v.Issuer = jwtIssuer
+		v.Audience = splitList(jwtAudience)
+		authorizer = v.Authorize
+	}
 
 	// create a reverse proxy
+	opts := []lambtrip.Option{
+		lambtrip.WithErrorResponse(true),
+		lambtrip.WithCodec(codec),
+		lambtrip.WithTrustedProxies(proxies...),
+		lambtrip.WithAuthorizerFunc(authorizer),
+	}
 	var t http.RoundTripper
 	switch invokeMode {
 	case "BUFFERED":
-		t = lambtrip.NewBufferedTransport(svc)
+		if localRuntime != nil {
+			t = lambtrip.NewLocalBufferedTransport(localRuntime, opts...)
+		} else {
+			t = lambtrip.NewBufferedTransport(lambda.NewFromConfig(cfg), opts...)
+		}
 	case "RESPONSE_STREAM":
-		t = lambtrip.NewResponseStreamTransport(svc)
+		if localRuntime != nil {
+			t = lambtrip.NewLocalResponseStreamTransport(localRuntime, opts...)
+		} else {
+			t = lambtrip.NewResponseStreamTransport(lambda.NewFromConfig(cfg), opts...)
+		}
 	default:
 		slog.ErrorContext(ctx, "unknown invoke mode", slog.String("mode", invokeMode))
 	}
@@ -65,20 +146,70 @@ func main() {
 		Director: func(req *http.Request) {
 			req.URL.Host = functionName
 		},
-		Transport: t,
-		ErrorLog:  slog.NewLogLogger(logHandler, slog.LevelWarn),
+		Transport:    t,
+		ErrorLog:     slog.NewLogLogger(logHandler, slog.LevelWarn),
+		ErrorHandler: errorHandler,
 	}
 	myLogger := httplogger.NewSlogLogger(slog.LevelInfo, "request", logger)
 	handler := httplogger.LoggingHandler(myLogger, proxy)
 
 	// start the server
 	addr := net.JoinHostPort(host, port)
-	if err := startServer(ctx, addr, handler); err != nil {
+	err = startServer(ctx, addr, handler)
+	if localRuntime != nil {
+		localRuntime.Close()
+	}
+	if err != nil {
 		slog.ErrorContext(ctx, "failed to start server", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 }
 
+func parseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range splitList(s) {
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// errorHandler responds 413 Content Too Large if the request is too large to invoke the function,
+// in the same way as Lambda Function URLs.
+// Otherwise, it responds 502 Bad Gateway in the same way as the default error handler of httputil.ReverseProxy.
+func errorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	var tooLarge *lambtrip.PayloadTooLargeError
+	var requestTooLarge *types.RequestTooLargeException
+	if errors.As(err, &tooLarge) || errors.As(err, &requestTooLarge) {
+		body := `{"Message":"Request must be smaller than 6291456 bytes for the InvokeFunction operation"}`
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("x-amzn-ErrorType", "RequestTooLargeException")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		io.WriteString(w, body)
+		return
+	}
+
+	slog.WarnContext(req.Context(), "proxy error", slog.String("error", err.Error()))
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+// splitList splits the comma-separated list, and removes empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		list = append(list, v)
+	}
+	return list
+}
+
 func startServer(ctx context.Context, addr string, handler http.Handler) error {
 	// start the server
 	ch := make(chan error, 1)
@@ -0,0 +1,101 @@
+package lambtrip
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// EventCodec converts HTTP requests into events for the function,
+// and converts the payloads returned by the function into HTTP responses.
+type EventCodec interface {
+	// EncodeRequest encodes req into the payload of the invocation.
+	EncodeRequest(req *http.Request) ([]byte, error)
+
+	// DecodeResponse decodes the payload returned by the function into the response for req.
+	// ResponseStreamTransport doesn't use it,
+	// because the streaming functions always send the response in the format of Lambda Function URLs.
+	DecodeResponse(payload []byte, req *http.Request) (*http.Response, error)
+}
+
+// EventFormat is the format of events that the function receives.
+// It implements EventCodec.
+type EventFormat string
+
+var _ EventCodec = EventFormat("")
+
+const (
+	// EventFormatFunctionURL is the format of Lambda Function URLs.
+	// It is same as the payload format version 2.0 of Amazon API Gateway HTTP APIs.
+	EventFormatFunctionURL EventFormat = "2.0"
+
+	// EventFormatAPIGatewayV1 is the format of Amazon API Gateway REST APIs (proxy integration).
+	// It is same as the payload format version 1.0 of Amazon API Gateway HTTP APIs.
+	EventFormatAPIGatewayV1 EventFormat = "1.0"
+
+	// EventFormatALB is the format of Application Load Balancer targets.
+	EventFormatALB EventFormat = "alb"
+
+	// EventFormatALBMultiValueHeaders is the format of Application Load Balancer targets
+	// that enable the lambda.multi_value_headers.enabled attribute.
+	EventFormatALBMultiValueHeaders EventFormat = "alb-multi-value-headers"
+
+	// EventFormatVPCLatticeV1 is the event format version 1.0 of Amazon VPC Lattice.
+	EventFormatVPCLatticeV1 EventFormat = "vpc-lattice-1.0"
+
+	// EventFormatVPCLatticeV2 is the event format version 2.0 of Amazon VPC Lattice.
+	EventFormatVPCLatticeV2 EventFormat = "vpc-lattice-2.0"
+)
+
+// supportsResponseStreaming reports whether the format supports response streaming.
+// ALB and VPC Lattice don't support it.
+func (f EventFormat) supportsResponseStreaming() bool {
+	switch f {
+	case EventFormatALB, EventFormatALBMultiValueHeaders, EventFormatVPCLatticeV1, EventFormatVPCLatticeV2:
+		return false
+	}
+	return true
+}
+
+// EncodeRequest implements EventCodec.
+func (f EventFormat) EncodeRequest(req *http.Request) ([]byte, error) {
+	var r any
+	var err error
+	switch f {
+	case "", EventFormatFunctionURL:
+		r, err = buildRequest(req, FunctionURLCodec{})
+	case EventFormatAPIGatewayV1:
+		r, err = buildRequestV1(req)
+	case EventFormatALB:
+		r, err = buildRequestALB(req, false)
+	case EventFormatALBMultiValueHeaders:
+		r, err = buildRequestALB(req, true)
+	case EventFormatVPCLatticeV1:
+		r, err = buildRequestLatticeV1(req)
+	case EventFormatVPCLatticeV2:
+		r, err = buildRequestLatticeV2(req)
+	default:
+		return nil, fmt.Errorf("lambtrip: unknown event format: %q", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// DecodeResponse implements EventCodec.
+func (f EventFormat) DecodeResponse(payload []byte, req *http.Request) (*http.Response, error) {
+	var resp response
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return nil, err
+	}
+	return buildResponse(&resp, req)
+}
+
+// codecOrDefault returns c if it is not nil, otherwise EventFormatFunctionURL.
+func codecOrDefault(c EventCodec) EventCodec {
+	if c == nil {
+		return EventFormatFunctionURL
+	}
+	return c
+}
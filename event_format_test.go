@@ -0,0 +1,122 @@
+package lambtrip
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+var _ EventCodec = (*testCodec)(nil)
+
+// testCodec sends the request path as the payload, and returns the payload as the response body.
+type testCodec struct{}
+
+func (testCodec) EncodeRequest(req *http.Request) ([]byte, error) {
+	return []byte(req.URL.Path), nil
+}
+
+func (testCodec) DecodeResponse(payload []byte, req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		ProtoMinor:    0,
+		Request:       req,
+		Header:        http.Header{},
+		ContentLength: int64(len(payload)),
+		Body:          io.NopCloser(strings.NewReader(string(payload))),
+	}, nil
+}
+
+func TestBufferedTransport_CustomCodec(t *testing.T) {
+	transport := &BufferedTransport{
+		Codec: testCodec{},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte("echo: " + string(params.Payload)),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "echo: /foo/bar" {
+		t.Errorf("body = %q, want %q", string(body), "echo: /foo/bar")
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransport_CustomCodec(t *testing.T) {
+	transport := &ResponseStreamTransport{
+		Codec: testCodec{},
+		lambda: func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
+			return &invokeWithResponseStreamOutput{
+				Output: &lambda.InvokeWithResponseStreamOutput{
+					StatusCode:                http.StatusOK,
+					ResponseStreamContentType: aws.String("application/vnd.awslambda.http-integration-response"),
+				},
+				StreamGetter: GetStreamMock(func() *lambda.InvokeWithResponseStreamEventStream {
+					stream := lambda.NewInvokeWithResponseStreamEventStream()
+					stream.Reader = newInvokeWithResponseStreamResponseEventReader([][]byte{
+						[]byte(`{}`),
+						{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+						[]byte("echo: " + string(params.Payload)),
+					})
+					return stream
+				}),
+			}, nil
+		},
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "echo: /foo/bar" {
+		t.Errorf("body = %q, want %q", string(body), "echo: /foo/bar")
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventFormat_UnknownFormat(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EventFormat("unknown").EncodeRequest(req); err == nil {
+		t.Error("want error, got nil")
+	}
+}
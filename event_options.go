@@ -0,0 +1,74 @@
+package lambtrip
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// eventOptions are the settings of the transports that change the conversion between requests and events.
+// They are passed to the codecs through the request context.
+type eventOptions struct {
+	binaryDetector     func(h http.Header) bool
+	defaultContentType string
+	protocol           string
+}
+
+type eventOptionsKey struct{}
+
+// withEventOptions returns a shallow copy of req whose context has o.
+// It returns req as is if o has no settings.
+func withEventOptions(req *http.Request, o eventOptions) *http.Request {
+	if o.binaryDetector == nil && o.defaultContentType == "" && o.protocol == "" {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), eventOptionsKey{}, o))
+}
+
+func eventOptionsFromRequest(req *http.Request) eventOptions {
+	o, _ := req.Context().Value(eventOptionsKey{}).(eventOptions)
+	return o
+}
+
+// isBinaryRequest reports whether the body of req is binary.
+func isBinaryRequest(req *http.Request) bool {
+	if fn := eventOptionsFromRequest(req).binaryDetector; fn != nil {
+		return fn(req.Header)
+	}
+	return isBinary(req.Header)
+}
+
+// requestProtocol returns the protocol of req such as "HTTP/1.1".
+func requestProtocol(req *http.Request) string {
+	if p := eventOptionsFromRequest(req).protocol; p != "" {
+		return p
+	}
+	if req.Proto == "" {
+		return "HTTP/1.1"
+	}
+	return req.Proto
+}
+
+// defaultContentType returns the Content-Type of the responses that do not specify it.
+func defaultContentType(req *http.Request) string {
+	if ct := eventOptionsFromRequest(req).defaultContentType; ct != "" {
+		return ct
+	}
+	return "application/json"
+}
+
+// requestQualifier returns the function qualifier of req.
+// If fn is nil, the qualifier is taken from the URL.
+func requestQualifier(fn func(req *http.Request) (*string, error), req *http.Request) (*string, error) {
+	if fn == nil {
+		return functionQualifier(req.URL)
+	}
+	qualifier, err := fn(req)
+	if err != nil {
+		return nil, err
+	}
+	if qualifier != nil && !isValidQualifier(*qualifier) {
+		return nil, fmt.Errorf("lambtrip: invalid function qualifier: %q", *qualifier)
+	}
+	return qualifier, nil
+}
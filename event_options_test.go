@@ -0,0 +1,87 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestBufferedTransport_EventOptions(t *testing.T) {
+	transport := NewBufferedTransport(
+		InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			if got := aws.ToString(params.Qualifier); got != "live" {
+				t.Errorf("params.Qualifier = %q, want %q", got, "live")
+			}
+			var req request
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if !req.IsBase64Encoded {
+				t.Error("req.IsBase64Encoded is false, want true")
+			}
+			if req.Body != "aGVsbG8=" {
+				t.Errorf("req.Body = %q, want %q", req.Body, "aGVsbG8=")
+			}
+			if got := req.RequestContext.HTTP.Protocol; got != "HTTP/2.0" {
+				t.Errorf("req.RequestContext.HTTP.Protocol = %q, want %q", got, "HTTP/2.0")
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+		WithBinaryDetector(func(h http.Header) bool {
+			return true
+		}),
+		WithDefaultContentType("text/plain"),
+		WithProtocol("HTTP/2.0"),
+		WithQualifierFunc(func(req *http.Request) (*string, error) {
+			return aws.String(req.Header.Get("X-Qualifier")), nil
+		}),
+	)
+
+	req, err := http.NewRequest(http.MethodPost, "lambda://function-name/foo/bar", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Qualifier", "live")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", got, "text/plain")
+	}
+	if resp.Request != req {
+		t.Errorf("resp.Request = %v, want %v", resp.Request, req)
+	}
+}
+
+func TestBufferedTransport_InvalidQualifierFunc(t *testing.T) {
+	transport := NewBufferedTransport(
+		InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			t.Error("the function must not be invoked")
+			return nil, errors.New("unexpected invocation")
+		}),
+		WithQualifierFunc(func(req *http.Request) (*string, error) {
+			return aws.String("invalid/qualifier"), nil
+		}),
+	)
+
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("want error, got nil")
+	}
+}
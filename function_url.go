@@ -0,0 +1,129 @@
+package lambtrip
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// FunctionURLCodec is the EventCodec of Lambda Function URLs with the details of the function URL.
+// The zero value is equivalent to EventFormatFunctionURL.
+type FunctionURLCodec struct {
+	// AccountID is the AWS account ID of the caller.
+	// If empty, the account ID of IAM is used.
+	// If both are empty, "anonymous" is used in the same way as the function URLs with the NONE auth type.
+	AccountID string
+
+	// URLID is the ID of the function URL.
+	// e.g. "abcdefghijklmnopqrstuvwxyz012345" of https://abcdefghijklmnopqrstuvwxyz012345.lambda-url.us-east-1.on.aws/
+	// If empty, the first label of the domain name is used.
+	URLID string
+
+	// DomainName is the domain name of the function URL.
+	// If empty, the Host of the request is used.
+	// e.g. "localhost:8080" when the request is forwarded by function-url-local.
+	DomainName string
+
+	// IAM is the IAM authorizer context sent as requestContext.authorizer.iam.
+	// It emulates the function URLs with the AWS_IAM auth type.
+	// Use NewIAMAuthorizer to resolve it from the credentials of the AWS SDK.
+	IAM *IAMAuthorizer
+
+	// Routes is the list of the route templates of API Gateway HTTP APIs.
+	// e.g. "/users/{id}", "GET /users/{id}", "ANY /files/{proxy+}"
+	// The route key and the path parameters of the event are taken from the most specific route that matches the request.
+	// If no route matches, the route key is "$default".
+	Routes []string
+
+	// Stage is the name of the stage.
+	// If empty, "$default" is used.
+	Stage string
+
+	// StageVariables is the stage variables sent as stageVariables.
+	StageVariables map[string]string
+}
+
+var _ EventCodec = FunctionURLCodec{}
+
+// EncodeRequest implements EventCodec.
+func (c FunctionURLCodec) EncodeRequest(req *http.Request) ([]byte, error) {
+	r, err := buildRequest(req, c)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(r)
+}
+
+// DecodeResponse implements EventCodec.
+func (c FunctionURLCodec) DecodeResponse(payload []byte, req *http.Request) (*http.Response, error) {
+	return EventFormatFunctionURL.DecodeResponse(payload, req)
+}
+
+func (c FunctionURLCodec) accountID() string {
+	if c.AccountID != "" {
+		return c.AccountID
+	}
+	if c.IAM != nil && c.IAM.AccountID != "" {
+		return c.IAM.AccountID
+	}
+	return "anonymous"
+}
+
+// authorizer returns the authorizer context of req.
+// The IAM authorizer context of c is used if the request context has no IAM authorizer context.
+func (c FunctionURLCodec) authorizer(req *http.Request) *Authorizer {
+	a, ok := AuthorizerFromContext(req.Context())
+	if c.IAM == nil {
+		return a
+	}
+	if !ok {
+		return &Authorizer{IAM: c.IAM}
+	}
+	if a.IAM != nil {
+		return a
+	}
+	merged := *a
+	merged.IAM = c.IAM
+	return &merged
+}
+
+func (c FunctionURLCodec) stage() string {
+	if c.Stage == "" {
+		return "$default"
+	}
+	return c.Stage
+}
+
+func (c FunctionURLCodec) domainName(req *http.Request) string {
+	if c.DomainName != "" {
+		return c.DomainName
+	}
+	return requestHost(req)
+}
+
+func (c FunctionURLCodec) urlID(domainName string) string {
+	if c.URLID != "" {
+		return c.URLID
+	}
+	return domainPrefix(domainName)
+}
+
+// requestHost returns the host of req.
+// It may contain the port number so that the function can build absolute URLs for local servers.
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// domainPrefix returns the first label of the domain name.
+// e.g. "abcdefghijklmnopqrstuvwxyz012345" of "abcdefghijklmnopqrstuvwxyz012345.lambda-url.us-east-1.on.aws"
+func domainPrefix(domainName string) string {
+	if host, _, err := net.SplitHostPort(domainName); err == nil {
+		domainName = host
+	}
+	prefix, _, _ := strings.Cut(domainName, ".")
+	return prefix
+}
@@ -0,0 +1,107 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestBufferedTransport_FunctionURLRequestContext(t *testing.T) {
+	transport := &BufferedTransport{
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req request
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if req.RequestContext.AccountID != "anonymous" {
+				t.Errorf("req.RequestContext.AccountID = %q, want %q", req.RequestContext.AccountID, "anonymous")
+			}
+			if req.RequestContext.APIID != "localhost" {
+				t.Errorf("req.RequestContext.APIID = %q, want %q", req.RequestContext.APIID, "localhost")
+			}
+			if req.RequestContext.DomainName != "localhost:8080" {
+				t.Errorf("req.RequestContext.DomainName = %q, want %q", req.RequestContext.DomainName, "localhost:8080")
+			}
+			if req.RequestContext.DomainPrefix != "localhost" {
+				t.Errorf("req.RequestContext.DomainPrefix = %q, want %q", req.RequestContext.DomainPrefix, "localhost")
+			}
+			if req.RequestContext.RouteKey != "$default" {
+				t.Errorf("req.RequestContext.RouteKey = %q, want %q", req.RequestContext.RouteKey, "$default")
+			}
+			if req.RequestContext.HTTP.Protocol != "HTTP/2.0" {
+				t.Errorf("req.RequestContext.HTTP.Protocol = %q, want %q", req.RequestContext.HTTP.Protocol, "HTTP/2.0")
+			}
+			if got := req.Headers["host"]; got != "localhost:8080" {
+				t.Errorf("req.Headers[%q] = %q, want %q", "host", got, "localhost:8080")
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// emulate the request forwarded by function-url-local
+	req.Host = "localhost:8080"
+	req.Proto = "HTTP/2.0"
+	req.ProtoMajor = 2
+	req.ProtoMinor = 0
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
+
+func TestBufferedTransport_FunctionURLCodec(t *testing.T) {
+	transport := &BufferedTransport{
+		Codec: FunctionURLCodec{
+			AccountID:  "123456789012",
+			URLID:      "abcdefghijklmnopqrstuvwxyz012345",
+			DomainName: "abcdefghijklmnopqrstuvwxyz012345.lambda-url.us-east-1.on.aws",
+		},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req request
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if req.RequestContext.AccountID != "123456789012" {
+				t.Errorf("req.RequestContext.AccountID = %q, want %q", req.RequestContext.AccountID, "123456789012")
+			}
+			if req.RequestContext.APIID != "abcdefghijklmnopqrstuvwxyz012345" {
+				t.Errorf("req.RequestContext.APIID = %q, want %q", req.RequestContext.APIID, "abcdefghijklmnopqrstuvwxyz012345")
+			}
+			if req.RequestContext.DomainName != "abcdefghijklmnopqrstuvwxyz012345.lambda-url.us-east-1.on.aws" {
+				t.Errorf("req.RequestContext.DomainName = %q, want %q", req.RequestContext.DomainName, "abcdefghijklmnopqrstuvwxyz012345.lambda-url.us-east-1.on.aws")
+			}
+			if req.RequestContext.DomainPrefix != "abcdefghijklmnopqrstuvwxyz012345" {
+				t.Errorf("req.RequestContext.DomainPrefix = %q, want %q", req.RequestContext.DomainPrefix, "abcdefghijklmnopqrstuvwxyz012345")
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
@@ -0,0 +1,201 @@
+package lambtrip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/shogo82148/lambtrip/internal/lambdahandler"
+)
+
+// defaultFunctionARN is the prefix of the function ARN passed to the handler.
+// The account ID is the placeholder used in the AWS documentation.
+const defaultFunctionARN = "arn:aws:lambda:us-east-1:123456789012:function:"
+
+var _ http.RoundTripper = (*HandlerTransport)(nil)
+
+// HandlerTransport is an http.RoundTripper that calls a Go Lambda handler in-process.
+// It converts requests into events and payloads into responses in the same way as BufferedTransport,
+// so tests can exercise the event translation without any AWS client or network.
+type HandlerTransport struct {
+	// ErrorResponse, if true, makes RoundTrip return a 502 Bad Gateway response instead of an error
+	// when the handler fails or returns a malformed payload,
+	// in the same way as Lambda Function URLs.
+	ErrorResponse bool
+
+	// Codec converts requests into events and payloads into responses.
+	// If nil, EventFormatFunctionURL is used.
+	Codec EventCodec
+
+	// ClientContextFunc returns the client context passed to the handler.
+	// If nil, the client context attached to the request context by WithClientContext is used.
+	ClientContextFunc func(req *http.Request) (*ClientContext, error)
+
+	// TrustedProxies is the list of networks of the trusted proxies.
+	// If the peer of the request is in them, the source IP address of the event is taken from
+	// the Forwarded header or the X-Forwarded-For header instead of RemoteAddr of the request.
+	TrustedProxies []netip.Prefix
+
+	// AuthorizerFunc returns the authorizer context sent to the handler.
+	// If it returns an error wrapping ErrUnauthorized, RoundTrip returns 401 Unauthorized
+	// without calling the handler.
+	// If nil, the authorizer context attached to the request context by WithAuthorizer is used.
+	AuthorizerFunc func(req *http.Request) (*Authorizer, error)
+
+	// BinaryDetector reports whether the request body is binary by the headers.
+	// The binary bodies are sent in base64.
+	// If nil, the bodies are binary unless they have text types, such as text/* and application/json,
+	// and no Content-Encoding.
+	BinaryDetector func(h http.Header) bool
+
+	// DefaultContentType is the Content-Type of the responses that don't specify it.
+	// If empty, "application/json" is used.
+	DefaultContentType string
+
+	// Protocol is the protocol sent in the event, such as "HTTP/1.1".
+	// If empty, the protocol of the request is used.
+	Protocol string
+
+	// QualifierFunc returns the function qualifier of the request.
+	// If nil, the user information of the URL is used, e.g. lambda://alias@function-name/foo/bar.
+	QualifierFunc func(req *http.Request) (*string, error)
+
+	// Timeout is the timeout of the function.
+	// If positive, the context passed to the handler has the deadline.
+	Timeout time.Duration
+
+	handler any
+}
+
+// NewHandlerTransport returns a new HandlerTransport that calls handler.
+// handler is one of the following:
+//
+//   - a function supported by lambda.Start of github.com/aws/aws-lambda-go/lambda,
+//     e.g. func(context.Context, events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error)
+//   - a function that handles the raw payload, func(context.Context, []byte) ([]byte, error)
+//   - a lambda.Handler
+func NewHandlerTransport(handler any, opts ...Option) *HandlerTransport {
+	o := newOptions(opts)
+	return &HandlerTransport{
+		ErrorResponse:      o.errorResponse,
+		Codec:              o.codec,
+		ClientContextFunc:  o.clientContextFunc,
+		TrustedProxies:     o.trustedProxies,
+		AuthorizerFunc:     o.authorizerFunc,
+		BinaryDetector:     o.binaryDetector,
+		DefaultContentType: o.defaultContentType,
+		Protocol:           o.protocol,
+		QualifierFunc:      o.qualifierFunc,
+		Timeout:            o.timeout,
+		handler:            handler,
+	}
+}
+
+func (t *HandlerTransport) eventOptions() eventOptions {
+	return eventOptions{
+		binaryDetector:     t.BinaryDetector,
+		defaultContentType: t.DefaultContentType,
+		protocol:           t.Protocol,
+	}
+}
+
+func (t *HandlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// build the request
+	eventReq, err := authorize(t.AuthorizerFunc, withEventOptions(withSourceIP(req, t.TrustedProxies), t.eventOptions()))
+	if errors.Is(err, ErrUnauthorized) {
+		return newUnauthorizedResponse(req), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkContentLength(eventReq, types.InvocationTypeRequestResponse); err != nil {
+		return nil, err
+	}
+	codec := codecOrDefault(t.Codec)
+	payload, err := codec.EncodeRequest(eventReq)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPayloadSize(payload, types.InvocationTypeRequestResponse); err != nil {
+		return nil, err
+	}
+
+	// call the handler
+	qualifier, err := requestQualifier(t.QualifierFunc, req)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := clientContext(t.ClientContextFunc, req)
+	if err != nil {
+		return nil, err
+	}
+	requestID, err := newRequestID()
+	if err != nil {
+		return nil, err
+	}
+	functionARN := defaultFunctionARN + req.URL.Host
+	if qualifier != nil {
+		functionARN += ":" + *qualifier
+	}
+	lc := &lambdacontext.LambdaContext{
+		AwsRequestID:       requestID,
+		InvokedFunctionArn: functionARN,
+		ClientContext:      lambdaClientContext(cc),
+	}
+
+	ctx := lambdacontext.NewContext(req.Context(), lc)
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	out, err := t.invoke(ctx, payload)
+	if err == nil {
+		var resp *http.Response
+		resp, err = codec.DecodeResponse(out, withEventOptions(req, t.eventOptions()))
+		if err == nil {
+			resp.Request = req
+			return resp, nil
+		}
+	}
+	if t.ErrorResponse {
+		return newErrorResponse(req, requestID, err), nil
+	}
+	return nil, err
+}
+
+// invoke calls the handler.
+// Errors and panics of the handler are converted into FunctionError.
+func (t *HandlerTransport) invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if fnErr := lambdahandler.Invoke(ctx, t.handler, payload, &buf); fnErr != nil {
+		return nil, &FunctionError{
+			FunctionError: "Unhandled",
+			ErrorMessage:  fnErr.ErrorMessage,
+			ErrorType:     fnErr.ErrorType,
+			StackTrace:    fnErr.StackTrace,
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func lambdaClientContext(cc *ClientContext) lambdacontext.ClientContext {
+	if cc == nil {
+		return lambdacontext.ClientContext{}
+	}
+	return lambdacontext.ClientContext{
+		Client: lambdacontext.ClientApplication{
+			InstallationID: cc.Client.InstallationID,
+			AppTitle:       cc.Client.AppTitle,
+			AppVersionCode: cc.Client.AppVersionCode,
+			AppPackageName: cc.Client.AppPackageName,
+		},
+		Env:    cc.Env,
+		Custom: cc.Custom,
+	}
+}
@@ -0,0 +1,164 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+func TestHandlerTransport(t *testing.T) {
+	transport := NewHandlerTransport(func(ctx context.Context, req events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+		if req.RequestContext.HTTP.Method != http.MethodPost {
+			t.Errorf("req.RequestContext.HTTP.Method = %q, want %q", req.RequestContext.HTTP.Method, http.MethodPost)
+		}
+		if req.RawPath != "/foo/bar" {
+			t.Errorf("req.RawPath = %q, want %q", req.RawPath, "/foo/bar")
+		}
+		if req.QueryStringParameters["q"] != "hello" {
+			t.Errorf("req.QueryStringParameters[%q] = %q, want %q", "q", req.QueryStringParameters["q"], "hello")
+		}
+		if req.Body != `{"hello":"world"}` {
+			t.Errorf("req.Body = %q, want %q", req.Body, `{"hello":"world"}`)
+		}
+
+		lc, ok := lambdacontext.FromContext(ctx)
+		if !ok {
+			t.Fatal("lambdacontext is not found")
+		}
+		if lc.AwsRequestID == "" {
+			t.Error("lc.AwsRequestID is empty")
+		}
+		if want := "arn:aws:lambda:us-east-1:123456789012:function:function-name:live"; lc.InvokedFunctionArn != want {
+			t.Errorf("lc.InvokedFunctionArn = %q, want %q", lc.InvokedFunctionArn, want)
+		}
+		if got := lc.ClientContext.Custom["foo"]; got != "bar" {
+			t.Errorf("lc.ClientContext.Custom[%q] = %q, want %q", "foo", got, "bar")
+		}
+
+		return events.LambdaFunctionURLResponse{
+			StatusCode: http.StatusCreated,
+			Headers: map[string]string{
+				"Content-Type": "text/plain",
+			},
+			Body: "created",
+		}, nil
+	})
+
+	ctx := WithClientContext(context.Background(), &ClientContext{
+		Custom: map[string]string{"foo": "bar"},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "lambda://live@function-name/foo/bar?q=hello", strings.NewReader(`{"hello":"world"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", got, "text/plain")
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "created" {
+		t.Errorf("body = %q, want %q", string(body), "created")
+	}
+}
+
+func TestHandlerTransport_RawHandler(t *testing.T) {
+	transport := NewHandlerTransport(func(ctx context.Context, payload []byte) ([]byte, error) {
+		var req request
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]any{
+			"statusCode": http.StatusOK,
+			"body":       req.RawPath,
+		})
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "/foo/bar" {
+		t.Errorf("body = %q, want %q", string(body), "/foo/bar")
+	}
+}
+
+type testHandlerError struct{}
+
+func (*testHandlerError) Error() string {
+	return "something wrong"
+}
+
+func TestHandlerTransport_FunctionError(t *testing.T) {
+	transport := NewHandlerTransport(func(ctx context.Context, req events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+		return events.LambdaFunctionURLResponse{}, &testHandlerError{}
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = transport.RoundTrip(req)
+	var functionErr *FunctionError
+	if !errors.As(err, &functionErr) {
+		t.Fatalf("want FunctionError, got %v", err)
+	}
+	if functionErr.ErrorType != "testHandlerError" {
+		t.Errorf("functionErr.ErrorType = %q, want %q", functionErr.ErrorType, "testHandlerError")
+	}
+	if functionErr.ErrorMessage != "something wrong" {
+		t.Errorf("functionErr.ErrorMessage = %q, want %q", functionErr.ErrorMessage, "something wrong")
+	}
+}
+
+func TestHandlerTransport_Panic(t *testing.T) {
+	transport := NewHandlerTransport(func(ctx context.Context, req events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+		panic("oops")
+	})
+	transport.ErrorResponse = true
+
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+	if got := resp.Header.Get("x-amzn-ErrorType"); got != "string" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "x-amzn-ErrorType", got, "string")
+	}
+}
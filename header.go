@@ -0,0 +1,125 @@
+package lambtrip
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// listHeaders is the set of the list-based headers defined by RFC 9110 and related specifications.
+// Their values can be joined with commas and split on commas without changing the meaning.
+// The keys are lower-cased.
+var listHeaders = map[string]struct{}{
+	"accept":                         {},
+	"accept-charset":                 {},
+	"accept-encoding":                {},
+	"accept-language":                {},
+	"accept-ranges":                  {},
+	"access-control-allow-headers":   {},
+	"access-control-allow-methods":   {},
+	"access-control-expose-headers":  {},
+	"access-control-request-headers": {},
+	"allow":                          {},
+	"cache-control":                  {},
+	"connection":                     {},
+	"content-encoding":               {},
+	"content-language":               {},
+	"forwarded":                      {},
+	"if-match":                       {},
+	"if-none-match":                  {},
+	"pragma":                         {},
+	"te":                             {},
+	"trailer":                        {},
+	"transfer-encoding":              {},
+	"upgrade":                        {},
+	"vary":                           {},
+	"via":                            {},
+	"x-forwarded-for":                {},
+}
+
+// buildHeaders converts the request headers into the headers of the events in the same way as Lambda Function URLs.
+// The names are lower-cased, and the values of the same list-based header are joined with commas.
+// If a header that is not list-based is repeated, only the first value is used,
+// because joining values that may contain commas, such as dates and credentials, breaks them.
+// The Cookie header is excluded because it is sent in the cookies field.
+func buildHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for k, v := range header {
+		name := strings.ToLower(k)
+		if name == "cookie" || len(v) == 0 {
+			continue
+		}
+		if prev, ok := headers[name]; ok {
+			// the header has the same name in different cases.
+			v = append([]string{prev}, v...)
+		}
+		headers[name] = joinHeaderValues(name, v)
+	}
+	return headers
+}
+
+// joinHeaderValues joins the values of the header with commas if the header is list-based.
+// Otherwise, it returns the first value.
+// name must be lower-cased.
+func joinHeaderValues(name string, values []string) string {
+	if _, ok := listHeaders[name]; ok {
+		return strings.Join(values, ",")
+	}
+	return values[0]
+}
+
+// splitHeaderValues splits the comma-separated value of the list-based header into its elements.
+// Commas in quoted strings are not treated as separators.
+// The values of the other headers are returned as is.
+func splitHeaderValues(name, value string) []string {
+	if _, ok := listHeaders[strings.ToLower(name)]; !ok {
+		return []string{value}
+	}
+
+	var values []string
+	quoted, escaped := false, false
+	start := 0
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ',':
+			if v := strings.TrimSpace(value[start:i]); v != "" {
+				values = append(values, v)
+			}
+			start = i + 1
+		}
+	}
+	if v := strings.TrimSpace(value[start:]); v != "" {
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return []string{value}
+	}
+	return values
+}
+
+// buildQueryStringParameters parses the query string in the same way as Lambda Function URLs.
+// The values are decoded, and the values of the same key are joined with commas.
+// It returns nil if the query string is empty.
+func buildQueryStringParameters(rawQuery string) map[string]string {
+	if rawQuery == "" {
+		return nil
+	}
+
+	// ignore the error, and use the parameters successfully parsed.
+	query, _ := url.ParseQuery(rawQuery)
+	if len(query) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(query))
+	for k, v := range query {
+		params[k] = strings.Join(v, ",")
+	}
+	return params
+}
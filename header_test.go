@@ -0,0 +1,206 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestBuildHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   map[string]string
+	}{
+		{
+			name:   "lower-cased names",
+			header: http.Header{"X-Foo": {"bar"}},
+			want:   map[string]string{"x-foo": "bar"},
+		},
+		{
+			name:   "list-based header",
+			header: http.Header{"Accept": {"text/html", "application/json"}},
+			want:   map[string]string{"accept": "text/html,application/json"},
+		},
+		{
+			name:   "custom header",
+			header: http.Header{"X-Foo": {"bar1", "bar2"}},
+			want:   map[string]string{"x-foo": "bar1"},
+		},
+		{
+			name:   "singleton header",
+			header: http.Header{"If-Modified-Since": {"Wed, 21 Oct 2015 07:28:00 GMT", "Thu, 22 Oct 2015 07:28:00 GMT"}},
+			want:   map[string]string{"if-modified-since": "Wed, 21 Oct 2015 07:28:00 GMT"},
+		},
+		{
+			name:   "authorization",
+			header: http.Header{"Authorization": {"Bearer token1", "Bearer token2"}},
+			want:   map[string]string{"authorization": "Bearer token1"},
+		},
+		{
+			name:   "cookie",
+			header: http.Header{"Cookie": {"foo=bar"}},
+			want:   map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildHeaders(tt.header)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitHeaderValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  []string
+	}{
+		{
+			name:  "list-based header",
+			key:   "Vary",
+			value: "Accept-Encoding, Origin",
+			want:  []string{"Accept-Encoding", "Origin"},
+		},
+		{
+			name:  "lower-cased name",
+			key:   "cache-control",
+			value: "no-cache,no-store",
+			want:  []string{"no-cache", "no-store"},
+		},
+		{
+			name:  "quoted string",
+			key:   "If-None-Match",
+			value: `"a,b", "c\",d"`,
+			want:  []string{`"a,b"`, `"c\",d"`},
+		},
+		{
+			name:  "empty elements",
+			key:   "Allow",
+			value: "GET,, HEAD,",
+			want:  []string{"GET", "HEAD"},
+		},
+		{
+			name:  "singleton header",
+			key:   "Expires",
+			value: "Wed, 21 Oct 2015 07:28:00 GMT",
+			want:  []string{"Wed, 21 Oct 2015 07:28:00 GMT"},
+		},
+		{
+			name:  "custom header",
+			key:   "X-Foo",
+			value: "bar1,bar2",
+			want:  []string{"bar1,bar2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitHeaderValues(tt.key, tt.value)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitHeaderValues(%q, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildQueryStringParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+		want     map[string]string
+	}{
+		{
+			name:     "empty",
+			rawQuery: "",
+			want:     nil,
+		},
+		{
+			name:     "simple",
+			rawQuery: "foo=bar",
+			want:     map[string]string{"foo": "bar"},
+		},
+		{
+			name:     "repeated",
+			rawQuery: "foo=bar1&foo=bar2",
+			want:     map[string]string{"foo": "bar1,bar2"},
+		},
+		{
+			name:     "decoded",
+			rawQuery: "foo=hello%20world&bar=a%2Cb",
+			want:     map[string]string{"foo": "hello world", "bar": "a,b"},
+		},
+		{
+			name:     "no value",
+			rawQuery: "foo",
+			want:     map[string]string{"foo": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildQueryStringParameters(tt.rawQuery)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildQueryStringParameters(%q) = %v, want %v", tt.rawQuery, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBufferedTransport_MultiValueHeaders(t *testing.T) {
+	transport := &BufferedTransport{
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req request
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if got, want := req.Headers["accept"], "text/html,application/json"; got != want {
+				t.Errorf("req.Headers[%q] = %q, want %q", "accept", got, want)
+			}
+			if got, want := req.Headers["x-foo"], "bar1"; got != want {
+				t.Errorf("req.Headers[%q] = %q, want %q", "x-foo", got, want)
+			}
+			if got, want := req.RawQueryString, "foo=bar1&foo=bar2"; got != want {
+				t.Errorf("req.RawQueryString = %q, want %q", got, want)
+			}
+			if got, want := req.QueryStringParameters["foo"], "bar1,bar2"; got != want {
+				t.Errorf("req.QueryStringParameters[%q] = %q, want %q", "foo", got, want)
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"headers": {"vary": "Accept-Encoding, Origin", "x-foo": "bar1,bar2"}, "body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar?foo=bar1&foo=bar2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-Foo", "bar1")
+	req.Header.Add("X-Foo", "bar2")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.Header.Values("Vary"), []string{"Accept-Encoding", "Origin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resp.Header.Values(%q) = %q, want %q", "Vary", got, want)
+	}
+	if got, want := resp.Header.Values("X-Foo"), []string{"bar1,bar2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resp.Header.Values(%q) = %q, want %q", "X-Foo", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package lambtrip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// IAMAuthorizer is the IAM authorizer context of Lambda Function URLs with the AWS_IAM auth type.
+// It is sent as requestContext.authorizer.iam of the event.
+type IAMAuthorizer struct {
+	// AccessKey is the access key ID of the caller.
+	AccessKey string `json:"accessKey"`
+
+	// AccountID is the AWS account ID of the caller.
+	AccountID string `json:"accountId"`
+
+	// CallerID is the unique identifier of the caller.
+	// e.g. "AIDACKCEVSQ6C2EXAMPLE" or "AROACKCEVSQ6C2EXAMPLE:session-name"
+	CallerID string `json:"callerId"`
+
+	// PrincipalOrgID is the ID of the AWS Organizations organization that the caller belongs to.
+	PrincipalOrgID string `json:"principalOrgId,omitempty"`
+
+	// UserARN is the ARN of the caller.
+	// e.g. "arn:aws:iam::123456789012:user/example-user"
+	UserARN string `json:"userArn"`
+
+	// UserID is the unique identifier of the caller.
+	UserID string `json:"userId"`
+}
+
+var _ getCallerIdentityAPIClient = (*sts.Client)(nil)
+
+type getCallerIdentityAPIClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// NewIAMAuthorizer returns the IAM authorizer context of the caller identified by the credentials of cfg.
+// It calls the GetCallerIdentity API of AWS STS to resolve the caller.
+func NewIAMAuthorizer(ctx context.Context, cfg aws.Config) (*IAMAuthorizer, error) {
+	return newIAMAuthorizer(ctx, cfg.Credentials, sts.NewFromConfig(cfg))
+}
+
+func newIAMAuthorizer(ctx context.Context, provider aws.CredentialsProvider, svc getCallerIdentityAPIClient) (*IAMAuthorizer, error) {
+	if provider == nil {
+		return nil, errors.New("lambtrip: no credentials provider")
+	}
+	cred, err := provider.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("lambtrip: failed to retrieve credentials: %w", err)
+	}
+
+	out, err := svc.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("lambtrip: failed to get the caller identity: %w", err)
+	}
+
+	return &IAMAuthorizer{
+		AccessKey: cred.AccessKeyID,
+		AccountID: aws.ToString(out.Account),
+		CallerID:  aws.ToString(out.UserId),
+		UserARN:   aws.ToString(out.Arn),
+		UserID:    aws.ToString(out.UserId),
+	}, nil
+}
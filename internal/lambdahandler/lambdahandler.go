@@ -0,0 +1,106 @@
+// Package lambdahandler calls Go Lambda handlers in-process.
+// It is shared by lambtrip.HandlerTransport and the fake endpoint of lambtriptest.
+package lambdahandler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime/debug"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Error is the error payload of the function.
+type Error struct {
+	ErrorMessage string   `json:"errorMessage"`
+	ErrorType    string   `json:"errorType"`
+	StackTrace   []string `json:"stackTrace,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.ErrorMessage
+}
+
+// Invoke calls handler with payload, and writes the response to w.
+// handler is one of the following:
+//
+//   - a function supported by lambda.Start of github.com/aws/aws-lambda-go/lambda
+//   - a function that handles the raw payload, func(context.Context, []byte) ([]byte, error)
+//   - a function that writes the response incrementally, func(context.Context, []byte, io.Writer) error
+//   - a lambda.Handler
+//
+// Errors and panics of the handler are converted into Error.
+func Invoke(ctx context.Context, handler any, payload []byte, w io.Writer) (fnErr *Error) {
+	defer func() {
+		if v := recover(); v != nil {
+			fnErr = &Error{
+				ErrorMessage: fmt.Sprint(v),
+				ErrorType:    ErrorTypeName(v),
+				StackTrace:   strings.Split(strings.TrimSpace(string(debug.Stack())), "\n"),
+			}
+		}
+	}()
+
+	var err error
+	switch handler := handler.(type) {
+	case func(context.Context, []byte, io.Writer) error:
+		err = handler(ctx, payload, w)
+	case func(context.Context, []byte) ([]byte, error):
+		var out []byte
+		out, err = handler(ctx, payload)
+		if err == nil {
+			_, err = w.Write(out)
+		}
+	default:
+		// create a new handler for each invocation
+		// because the handlers created by lambda.NewHandler are not safe for concurrent use.
+		var out []byte
+		out, err = lambda.NewHandler(handler).Invoke(ctx, payload)
+		if err == nil {
+			_, err = w.Write(out)
+		}
+	}
+	if err != nil {
+		return &Error{
+			ErrorMessage: err.Error(),
+			ErrorType:    ErrorTypeName(err),
+		}
+	}
+	return nil
+}
+
+// ErrorTypeName returns the name of the type of v in the same way as the Go runtime of Lambda.
+func ErrorTypeName(v any) string {
+	typ := reflect.TypeOf(v)
+	if typ.Kind() == reflect.Pointer {
+		return typ.Elem().Name()
+	}
+	return typ.Name()
+}
+
+// NewRequestID returns a new request ID in the form of UUID version 4.
+func NewRequestID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	buf[6] = (buf[6] & 0x0f) | 0x40 // set version to 4
+	buf[8] = (buf[8] & 0x3f) | 0x80 // set variant to 10
+
+	var dst [36]byte
+	hex.Encode(dst[:], buf[:4])
+	dst[8] = '-'
+	hex.Encode(dst[9:], buf[4:6])
+	dst[13] = '-'
+	hex.Encode(dst[14:], buf[6:8])
+	dst[18] = '-'
+	hex.Encode(dst[19:], buf[8:10])
+	dst[23] = '-'
+	hex.Encode(dst[24:], buf[10:])
+	return string(dst[:]), nil
+}
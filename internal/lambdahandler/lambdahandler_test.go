@@ -0,0 +1,95 @@
+package lambdahandler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+type myError struct{}
+
+func (*myError) Error() string {
+	return "my error"
+}
+
+func TestInvoke(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler any
+		want    string
+	}{
+		{
+			name: "raw",
+			handler: func(ctx context.Context, payload []byte) ([]byte, error) {
+				return payload, nil
+			},
+			want: `"hello"`,
+		},
+		{
+			name: "streaming",
+			handler: func(ctx context.Context, payload []byte, w io.Writer) error {
+				w.Write([]byte("hello "))
+				w.Write(payload)
+				return nil
+			},
+			want: `hello "hello"`,
+		},
+		{
+			name: "lambda.Start",
+			handler: func(ctx context.Context, s string) (string, error) {
+				return s + " world", nil
+			},
+			want: `"hello world"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Invoke(context.Background(), tt.handler, []byte(`"hello"`), &buf); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvoke_Error(t *testing.T) {
+	fnErr := Invoke(context.Background(), func(ctx context.Context) error {
+		return &myError{}
+	}, []byte(`{}`), io.Discard)
+	if fnErr == nil {
+		t.Fatal("want error, got nil")
+	}
+	if fnErr.ErrorMessage != "my error" {
+		t.Errorf("ErrorMessage = %q, want %q", fnErr.ErrorMessage, "my error")
+	}
+	if fnErr.ErrorType != "myError" {
+		t.Errorf("ErrorType = %q, want %q", fnErr.ErrorType, "myError")
+	}
+	if fnErr.StackTrace != nil {
+		t.Errorf("StackTrace = %v, want nil", fnErr.StackTrace)
+	}
+}
+
+func TestInvoke_Panic(t *testing.T) {
+	fnErr := Invoke(context.Background(), func(ctx context.Context, payload []byte) ([]byte, error) {
+		panic(errors.New("oops"))
+	}, []byte(`{}`), io.Discard)
+	if fnErr == nil {
+		t.Fatal("want error, got nil")
+	}
+	if fnErr.ErrorMessage != "oops" {
+		t.Errorf("ErrorMessage = %q, want %q", fnErr.ErrorMessage, "oops")
+	}
+	if fnErr.ErrorType != "errorString" {
+		t.Errorf("ErrorType = %q, want %q", fnErr.ErrorType, "errorString")
+	}
+	if len(fnErr.StackTrace) == 0 {
+		t.Error("StackTrace is empty")
+	}
+}
@@ -0,0 +1,384 @@
+package lambtrip
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTVerifier verifies JSON Web Tokens in the same way as JWT authorizers of API Gateway HTTP APIs.
+// Its Authorize method can be used as AuthorizerFunc of the transports.
+type JWTVerifier struct {
+	// Issuer is the expected value of the "iss" claim.
+	// If empty, the issuer is not verified.
+	Issuer string
+
+	// Audience is the list of the expected values of the "aud" or "client_id" claim.
+	// If empty, the audience is not verified.
+	Audience []string
+
+	keys []jwk
+	now  func() time.Time
+}
+
+// jwk is a public key in JSON Web Key format.
+type jwk struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// NewJWTVerifier returns a new JWTVerifier with the JSON Web Key Set in the file.
+func NewJWTVerifier(jwksFile string) (*JWTVerifier, error) {
+	data, err := os.ReadFile(jwksFile)
+	if err != nil {
+		return nil, err
+	}
+	return newJWTVerifier(data)
+}
+
+func newJWTVerifier(jwks []byte) (*JWTVerifier, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		return nil, fmt.Errorf("lambtrip: failed to parse JWKS: %w", err)
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for _, raw := range set.Keys {
+		key, err := parseJWK(raw)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			// the key is not for signature verification.
+			continue
+		}
+		keys = append(keys, *key)
+	}
+	return &JWTVerifier{
+		keys: keys,
+	}, nil
+}
+
+func parseJWK(data []byte) (*jwk, error) {
+	var v struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("lambtrip: failed to parse JWK: %w", err)
+	}
+	if v.Use != "" && v.Use != "sig" {
+		return nil, nil
+	}
+
+	switch v.Kty {
+	case "RSA":
+		n, err := decodeBigInt(v.N)
+		if err != nil {
+			return nil, fmt.Errorf("lambtrip: invalid JWK %q: %w", v.Kid, err)
+		}
+		e, err := decodeBigInt(v.E)
+		if err != nil {
+			return nil, fmt.Errorf("lambtrip: invalid JWK %q: %w", v.Kid, err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("lambtrip: invalid JWK %q: exponent is too large", v.Kid)
+		}
+		return &jwk{
+			kid: v.Kid,
+			alg: v.Alg,
+			key: &rsa.PublicKey{N: n, E: int(e.Int64())},
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch v.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("lambtrip: invalid JWK %q: unsupported curve: %q", v.Kid, v.Crv)
+		}
+		x, err := decodeBigInt(v.X)
+		if err != nil {
+			return nil, fmt.Errorf("lambtrip: invalid JWK %q: %w", v.Kid, err)
+		}
+		y, err := decodeBigInt(v.Y)
+		if err != nil {
+			return nil, fmt.Errorf("lambtrip: invalid JWK %q: %w", v.Kid, err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("lambtrip: invalid JWK %q: the point is not on the curve", v.Kid)
+		}
+		return &jwk{
+			kid: v.Kid,
+			alg: v.Alg,
+			key: &ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+		}, nil
+	default:
+		// unsupported key types are ignored.
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// Authorize verifies the bearer token in the Authorization header of req,
+// and returns the authorizer context with the claims of the token.
+// It returns an error wrapping ErrUnauthorized if the token is missing or invalid.
+func (v *JWTVerifier) Authorize(req *http.Request) (*Authorizer, error) {
+	auth := req.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, fmt.Errorf("%w: bearer token is missing", ErrUnauthorized)
+	}
+
+	claims, err := v.verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	return &Authorizer{
+		JWT: &JWTAuthorizer{
+			Claims: jwtClaims(claims),
+			Scopes: jwtScopes(claims),
+		},
+	}, nil
+}
+
+// verify verifies the signature and the claims of the token, and returns its claims.
+func (v *JWTVerifier) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	// parse the header
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	// verify the signature
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	if err := v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	// parse the claims
+	data, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var claims map[string]any
+	if err := dec.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+	if err := v.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) verifySignature(alg, kid, signingInput string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm: %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	for _, key := range v.keys {
+		if kid != "" && key.kid != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		switch pub := key.key.(type) {
+		case *rsa.PublicKey:
+			if strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(pub, hash, digest, sig) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if strings.HasPrefix(alg, "ES") && verifyECDSA(pub, digest, sig) {
+				return nil
+			}
+		}
+	}
+	return errors.New("invalid token signature")
+}
+
+// verifyECDSA verifies the signature in the JWS format, which is the concatenation of R and S.
+func verifyECDSA(pub *ecdsa.PublicKey, digest, sig []byte) bool {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if len(sig) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	return ecdsa.Verify(pub, digest, r, s)
+}
+
+func (v *JWTVerifier) verifyClaims(claims map[string]any) error {
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+
+	// API Gateway requires the exp claim.
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return errors.New("exp claim is missing")
+	}
+	if !now.Before(exp) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+	if iat, ok := numericDate(claims["iat"]); ok && now.Before(iat) {
+		return errors.New("token is issued in the future")
+	}
+
+	if v.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Issuer {
+			return fmt.Errorf("invalid issuer: %q", iss)
+		}
+	}
+
+	if len(v.Audience) > 0 {
+		var audience []string
+		switch aud := claims["aud"].(type) {
+		case string:
+			audience = append(audience, aud)
+		case []any:
+			for _, a := range aud {
+				if s, ok := a.(string); ok {
+					audience = append(audience, s)
+				}
+			}
+		}
+		if clientID, ok := claims["client_id"].(string); ok {
+			audience = append(audience, clientID)
+		}
+		if !containsAny(v.Audience, audience) {
+			return errors.New("invalid audience")
+		}
+	}
+	return nil
+}
+
+func numericDate(v any) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec := int64(f)
+	nsec := int64((f - float64(sec)) * 1e9)
+	return time.Unix(sec, nsec), true
+}
+
+func containsAny(values, candidates []string) bool {
+	for _, v := range candidates {
+		if containsValue(values, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// jwtClaims converts the claims into the string map in the same way as API Gateway.
+func jwtClaims(claims map[string]any) map[string]string {
+	ret := make(map[string]string, len(claims))
+	for k, v := range claims {
+		switch v := v.(type) {
+		case string:
+			ret[k] = v
+		case json.Number:
+			ret[k] = v.String()
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				continue
+			}
+			ret[k] = string(data)
+		}
+	}
+	return ret
+}
+
+// jwtScopes returns the scopes in the "scope" or "scp" claim.
+func jwtScopes(claims map[string]any) []string {
+	if v, ok := claims["scope"].(string); ok {
+		return strings.Fields(v)
+	}
+	switch v := claims["scp"].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		scopes := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+		return scopes
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package lambtrip
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signingInput))
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	jwks := map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa-key",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   enc.EncodeToString(rsaKey.N.Bytes()),
+				"e":   enc.EncodeToString([]byte{0x01, 0x00, 0x01}),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec-key",
+				"crv": "P-256",
+				"x":   enc.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+				"y":   enc.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		},
+	}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewJWTVerifier(writeJWKS(t, rsaKey, ecKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Issuer = "https://issuer.example.com"
+	v.Audience = []string{"my-app"}
+	now := time.Unix(1700000000, 0)
+	v.now = func() time.Time { return now }
+
+	claims := func(override map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":   "https://issuer.example.com",
+			"aud":   "my-app",
+			"sub":   "user-1234",
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"scope": "read write",
+		}
+		for k, v := range override {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{
+			name:  "rsa",
+			token: signJWT(t, "RS256", "rsa-key", rsaKey, claims(nil)),
+			ok:    true,
+		},
+		{
+			name:  "ecdsa",
+			token: signJWT(t, "ES256", "ec-key", ecKey, claims(nil)),
+			ok:    true,
+		},
+		{
+			name:  "client_id",
+			token: signJWT(t, "RS256", "rsa-key", rsaKey, claims(map[string]any{"aud": nil, "client_id": "my-app"})),
+			ok:    true,
+		},
+		{
+			name:  "unknown key",
+			token: signJWT(t, "RS256", "rsa-key", otherKey, claims(nil)),
+		},
+		{
+			name:  "algorithm mismatch",
+			token: signJWT(t, "RS256", "ec-key", rsaKey, claims(nil)),
+		},
+		{
+			name:  "expired",
+			token: signJWT(t, "RS256", "rsa-key", rsaKey, claims(map[string]any{"exp": now.Add(-time.Second).Unix()})),
+		},
+		{
+			name:  "no exp",
+			token: signJWT(t, "RS256", "rsa-key", rsaKey, claims(map[string]any{"exp": nil})),
+		},
+		{
+			name:  "not before",
+			token: signJWT(t, "RS256", "rsa-key", rsaKey, claims(map[string]any{"nbf": now.Add(time.Minute).Unix()})),
+		},
+		{
+			name:  "invalid issuer",
+			token: signJWT(t, "RS256", "rsa-key", rsaKey, claims(map[string]any{"iss": "https://evil.example.com"})),
+		},
+		{
+			name:  "invalid audience",
+			token: signJWT(t, "RS256", "rsa-key", rsaKey, claims(map[string]any{"aud": []string{"other-app"}})),
+		},
+		{
+			name:  "malformed",
+			token: "malformed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "lambda://function-name/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			a, err := v.Authorize(req)
+			if !tt.ok {
+				if !errors.Is(err, ErrUnauthorized) {
+					t.Errorf("want ErrUnauthorized, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.JWT.Claims["sub"]; got != "user-1234" {
+				t.Errorf("sub = %q, want %q", got, "user-1234")
+			}
+			if got := a.JWT.Claims["exp"]; got != "1700003600" {
+				t.Errorf("exp = %q, want %q", got, "1700003600")
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_MissingToken(t *testing.T) {
+	v, err := newJWTVerifier([]byte(`{"keys":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Authorize(req); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("want ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestBufferedTransport_Authorizer(t *testing.T) {
+	transport := &BufferedTransport{
+		AuthorizerFunc: func(req *http.Request) (*Authorizer, error) {
+			if req.Header.Get("Authorization") != "secret" {
+				return nil, ErrUnauthorized
+			}
+			return &Authorizer{
+				JWT: &JWTAuthorizer{
+					Claims: map[string]string{"sub": "user-1234"},
+					Scopes: []string{"read"},
+				},
+				Lambda: map[string]any{"role": "admin"},
+			}, nil
+		},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req request
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			a := req.RequestContext.Authorizer
+			if a == nil || a.JWT == nil {
+				t.Fatal("authorizer context is missing")
+			}
+			if got := a.JWT.Claims["sub"]; got != "user-1234" {
+				t.Errorf("sub = %q, want %q", got, "user-1234")
+			}
+			if got := a.Lambda["role"]; got != "admin" {
+				t.Errorf("role = %v, want %q", got, "admin")
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	t.Run("authorized", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "secret")
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+	})
+}
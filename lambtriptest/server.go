@@ -0,0 +1,378 @@
+// Package lambtriptest provides a fake AWS Lambda endpoint for testing.
+//
+// The server speaks the wire protocol of the Invoke and InvokeWithResponseStream APIs,
+// so the code using lambtrip.NewBufferedTransport and lambtrip.NewResponseStreamTransport
+// can be tested end to end with the serializers of the AWS SDK.
+package lambtriptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	lambdasdk "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/shogo82148/lambtrip/internal/lambdahandler"
+)
+
+const (
+	// Region is the region of the fake endpoint.
+	Region = "us-east-1"
+
+	// AccountID is the account ID of the functions.
+	AccountID = "123456789012"
+)
+
+const (
+	invokePathPrefix       = "/2015-03-31/functions/"
+	invokePathSuffix       = "/invocations"
+	streamingPathPrefix    = "/2021-11-15/functions/"
+	streamingPathSuffix    = "/response-streaming-invocations"
+	maxPayloadSize         = 6 * 1024 * 1024
+	defaultExecutedVersion = "$LATEST"
+)
+
+// StreamingHandlerFunc is a handler that writes the response incrementally.
+// Each write is sent to the client as a chunk of the response stream
+// when the function is invoked by InvokeWithResponseStream.
+type StreamingHandlerFunc func(ctx context.Context, payload []byte, w io.Writer) error
+
+// Server is a fake AWS Lambda endpoint.
+// It routes invocations to the handlers by the function name.
+type Server struct {
+	// URL is the base URL of the endpoint, of the form http://ipaddr:port with no trailing slash.
+	URL string
+
+	server *httptest.Server
+
+	mu       sync.RWMutex
+	handlers map[string]any
+
+	// wg waits for the asynchronous invocations.
+	wg sync.WaitGroup
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		handlers: make(map[string]any),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Handle registers the handler for the function.
+// handler is one of the following:
+//
+//   - a function supported by lambda.Start of github.com/aws/aws-lambda-go/lambda,
+//     e.g. func(context.Context, events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error)
+//   - a function that handles the raw payload, func(context.Context, []byte) ([]byte, error)
+//   - a lambda.Handler
+//   - a StreamingHandlerFunc
+//
+// The qualifier of the invocation is ignored.
+func (s *Server) Handle(functionName string, handler any) {
+	if h, ok := handler.(StreamingHandlerFunc); ok {
+		handler = (func(context.Context, []byte, io.Writer) error)(h)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[functionName] = handler
+}
+
+// Client returns a new Lambda client configured to send the requests to the server.
+func (s *Server) Client(optFns ...func(*lambdasdk.Options)) *lambdasdk.Client {
+	return lambdasdk.New(lambdasdk.Options{
+		Region:       Region,
+		BaseEndpoint: aws.String(s.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		HTTPClient:   s.server.Client(),
+	}, optFns...)
+}
+
+// Close shuts down the server and blocks until all outstanding requests on this server,
+// including the asynchronous invocations, have completed.
+func (s *Server) Close() {
+	s.server.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) handler(functionName string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.handlers[functionName]
+	return h, ok
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "", "MethodNotAllowedException", "Method not allowed")
+		return
+	}
+
+	path := req.URL.EscapedPath()
+	if name, ok := cutPath(path, invokePathPrefix, invokePathSuffix); ok {
+		s.serveInvoke(w, req, name)
+		return
+	}
+	if name, ok := cutPath(path, streamingPathPrefix, streamingPathSuffix); ok {
+		s.serveInvokeWithResponseStream(w, req, name)
+		return
+	}
+	writeError(w, http.StatusNotFound, "", "UnknownOperationException", "Unknown operation")
+}
+
+// serveInvoke serves the Invoke API.
+func (s *Server) serveInvoke(w http.ResponseWriter, req *http.Request, functionName string) {
+	inv, ok := s.newInvocation(w, req, functionName)
+	if !ok {
+		return
+	}
+
+	switch req.Header.Get("X-Amz-Invocation-Type") {
+	case "", "RequestResponse":
+	case "Event":
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			inv.invoke(context.Background(), io.Discard)
+		}()
+		w.Header().Set("X-Amzn-Requestid", inv.requestID)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case "DryRun":
+		w.Header().Set("X-Amzn-Requestid", inv.requestID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		writeError(w, http.StatusBadRequest, inv.requestID, "InvalidParameterValueException", "Invalid invocation type")
+		return
+	}
+
+	var buf bytes.Buffer
+	fnErr := inv.invoke(req.Context(), &buf)
+
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Amzn-Requestid", inv.requestID)
+	h.Set("X-Amz-Executed-Version", defaultExecutedVersion)
+	if req.Header.Get("X-Amz-Log-Type") == "Tail" {
+		h.Set("X-Amz-Log-Result", inv.logResult())
+	}
+	payload := buf.Bytes()
+	if fnErr != nil {
+		h.Set("X-Amz-Function-Error", "Unhandled")
+		payload, _ = json.Marshal(fnErr)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
+// serveInvokeWithResponseStream serves the InvokeWithResponseStream API.
+func (s *Server) serveInvokeWithResponseStream(w http.ResponseWriter, req *http.Request, functionName string) {
+	inv, ok := s.newInvocation(w, req, functionName)
+	if !ok {
+		return
+	}
+
+	switch req.Header.Get("X-Amz-Invocation-Type") {
+	case "", "RequestResponse":
+	case "DryRun":
+		w.Header().Set("X-Amzn-Requestid", inv.requestID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		writeError(w, http.StatusBadRequest, inv.requestID, "InvalidParameterValueException", "Invalid invocation type")
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("X-Amzn-Requestid", inv.requestID)
+	h.Set("X-Amz-Executed-Version", defaultExecutedVersion)
+	w.WriteHeader(http.StatusOK)
+
+	sw := &streamWriter{w: w, enc: eventstream.NewEncoder()}
+	fnErr := inv.invoke(req.Context(), sw)
+	complete := map[string]string{}
+	if fnErr != nil {
+		complete["ErrorCode"] = fnErr.ErrorType
+		complete["ErrorDetails"] = fnErr.ErrorMessage
+	}
+	if req.Header.Get("X-Amz-Log-Type") == "Tail" {
+		complete["LogResult"] = inv.logResult()
+	}
+	data, _ := json.Marshal(complete)
+	sw.writeEvent("InvokeComplete", "application/json", data)
+}
+
+// newInvocation parses the request.
+// It writes the error response and returns false if the request is invalid.
+func (s *Server) newInvocation(w http.ResponseWriter, req *http.Request, functionName string) (*invocation, bool) {
+	requestID, err := lambdahandler.NewRequestID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "", "ServiceException", err.Error())
+		return nil, false
+	}
+
+	name, qualifier := splitFunctionName(functionName)
+	if q := req.URL.Query().Get("Qualifier"); q != "" {
+		qualifier = q
+	}
+	functionARN := "arn:aws:lambda:" + Region + ":" + AccountID + ":function:" + name
+	if qualifier != "" {
+		functionARN += ":" + qualifier
+	}
+	handler, ok := s.handler(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, requestID, "ResourceNotFoundException", "Function not found: "+functionARN)
+		return nil, false
+	}
+
+	var cc lambdacontext.ClientContext
+	if v := req.Header.Get("X-Amz-Client-Context"); v != "" {
+		data, err := base64.StdEncoding.DecodeString(v)
+		if err == nil {
+			err = json.Unmarshal(data, &cc)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, requestID, "InvalidRequestContentException", "Client context must be a valid Base64-encoded JSON object.")
+			return nil, false
+		}
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(req.Body, maxPayloadSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, requestID, "InvalidRequestContentException", err.Error())
+		return nil, false
+	}
+	if len(payload) > maxPayloadSize {
+		writeError(w, http.StatusRequestEntityTooLarge, requestID, "RequestTooLargeException", fmt.Sprintf("Request must be smaller than %d bytes for the InvokeFunction operation", maxPayloadSize))
+		return nil, false
+	}
+
+	return &invocation{
+		requestID:   requestID,
+		functionARN: functionARN,
+		handler:     handler,
+		payload:     payload,
+		lc: &lambdacontext.LambdaContext{
+			AwsRequestID:       requestID,
+			InvokedFunctionArn: functionARN,
+			ClientContext:      cc,
+		},
+	}, true
+}
+
+type invocation struct {
+	requestID   string
+	functionARN string
+	handler     any
+	payload     []byte
+	lc          *lambdacontext.LambdaContext
+}
+
+// invoke calls the handler, and writes the response to w.
+// Errors and panics of the handler are converted into the error payload.
+func (inv *invocation) invoke(ctx context.Context, w io.Writer) *lambdahandler.Error {
+	return lambdahandler.Invoke(lambdacontext.NewContext(ctx, inv.lc), inv.handler, inv.payload, w)
+}
+
+// logResult returns the base64-encoded execution log.
+func (inv *invocation) logResult() string {
+	log := fmt.Sprintf("START RequestId: %[1]s Version: %[2]s\nEND RequestId: %[1]s\nREPORT RequestId: %[1]s\n", inv.requestID, defaultExecutedVersion)
+	return base64.StdEncoding.EncodeToString([]byte(log))
+}
+
+// streamWriter writes each chunk as a PayloadChunk event of the response stream.
+type streamWriter struct {
+	w   http.ResponseWriter
+	enc *eventstream.Encoder
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	chunk := make([]byte, len(p))
+	copy(chunk, p)
+	if err := w.writeEvent("PayloadChunk", "application/octet-stream", chunk); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *streamWriter) writeEvent(eventType, contentType string, payload []byte) error {
+	msg := eventstream.Message{
+		Payload: payload,
+	}
+	msg.Headers.Set(":message-type", eventstream.StringValue("event"))
+	msg.Headers.Set(":event-type", eventstream.StringValue(eventType))
+	msg.Headers.Set(":content-type", eventstream.StringValue(contentType))
+	if err := w.enc.Encode(w.w, msg); err != nil {
+		return err
+	}
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// cutPath returns the escaped function name in path.
+func cutPath(path, prefix, suffix string) (string, bool) {
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+		return "", false
+	}
+	name, err := url.PathUnescape(path[len(prefix) : len(path)-len(suffix)])
+	if err != nil || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// splitFunctionName splits the function name, the function ARN, or the partial ARN
+// into the function name and the qualifier.
+func splitFunctionName(s string) (name, qualifier string) {
+	parts := strings.Split(s, ":")
+	switch {
+	case len(parts) >= 7 && parts[0] == "arn":
+		// arn:aws:lambda:us-east-1:123456789012:function:my-function[:qualifier]
+		parts = parts[6:]
+	case len(parts) >= 3 && parts[1] == "function":
+		// 123456789012:function:my-function[:qualifier]
+		parts = parts[2:]
+	}
+	name = parts[0]
+	if len(parts) > 1 {
+		qualifier = parts[1]
+	}
+	return name, qualifier
+}
+
+func writeError(w http.ResponseWriter, statusCode int, requestID, errorType, message string) {
+	data, _ := json.Marshal(map[string]string{
+		"Type":    "User",
+		"message": message,
+	})
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Amzn-Errortype", errorType)
+	if requestID != "" {
+		h.Set("X-Amzn-Requestid", requestID)
+	}
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
@@ -0,0 +1,265 @@
+package lambtriptest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/shogo82148/lambtrip"
+)
+
+func TestServer_Invoke(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle("function-name", func(ctx context.Context, payload []byte) ([]byte, error) {
+		lc, ok := lambdacontext.FromContext(ctx)
+		if !ok {
+			t.Error("lambdacontext is not found")
+		}
+		if want := "arn:aws:lambda:us-east-1:123456789012:function:function-name:live"; lc.InvokedFunctionArn != want {
+			t.Errorf("lc.InvokedFunctionArn = %q, want %q", lc.InvokedFunctionArn, want)
+		}
+		if got := lc.ClientContext.Custom["foo"]; got != "bar" {
+			t.Errorf("lc.ClientContext.Custom[%q] = %q, want %q", "foo", got, "bar")
+		}
+		return payload, nil
+	})
+
+	out, err := s.Client().Invoke(context.Background(), &lambda.InvokeInput{
+		FunctionName:  aws.String("function-name"),
+		Qualifier:     aws.String("live"),
+		ClientContext: aws.String("eyJjdXN0b20iOnsiZm9vIjoiYmFyIn19"), // {"custom":{"foo":"bar"}}
+		LogType:       types.LogTypeTail,
+		Payload:       []byte(`{"hello":"world"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusOK {
+		t.Errorf("out.StatusCode = %d, want %d", out.StatusCode, http.StatusOK)
+	}
+	if string(out.Payload) != `{"hello":"world"}` {
+		t.Errorf("out.Payload = %q, want %q", out.Payload, `{"hello":"world"}`)
+	}
+	if out.FunctionError != nil {
+		t.Errorf("out.FunctionError = %q, want nil", *out.FunctionError)
+	}
+	if aws.ToString(out.LogResult) == "" {
+		t.Error("out.LogResult is empty")
+	}
+	if requestID, _ := awsmiddleware.GetRequestIDMetadata(out.ResultMetadata); requestID == "" {
+		t.Error("request ID is empty")
+	}
+}
+
+func TestServer_FunctionError(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle("function-name", func(ctx context.Context) error {
+		return errors.New("something went wrong")
+	})
+
+	out, err := s.Client().Invoke(context.Background(), &lambda.InvokeInput{
+		FunctionName: aws.String("function-name"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.ToString(out.FunctionError); got != "Unhandled" {
+		t.Errorf("out.FunctionError = %q, want %q", got, "Unhandled")
+	}
+	var payload struct {
+		ErrorMessage string `json:"errorMessage"`
+		ErrorType    string `json:"errorType"`
+	}
+	if err := json.Unmarshal(out.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ErrorMessage != "something went wrong" {
+		t.Errorf("errorMessage = %q, want %q", payload.ErrorMessage, "something went wrong")
+	}
+	if payload.ErrorType != "errorString" {
+		t.Errorf("errorType = %q, want %q", payload.ErrorType, "errorString")
+	}
+}
+
+func TestServer_Event(t *testing.T) {
+	s := NewServer()
+	done := make(chan struct{})
+	s.Handle("function-name", func(ctx context.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		close(done)
+		return nil
+	})
+
+	out, err := s.Client().Invoke(context.Background(), &lambda.InvokeInput{
+		FunctionName:   aws.String("function-name"),
+		InvocationType: types.InvocationTypeEvent,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusAccepted {
+		t.Errorf("out.StatusCode = %d, want %d", out.StatusCode, http.StatusAccepted)
+	}
+
+	// Close waits for the asynchronous invocation.
+	s.Close()
+	select {
+	case <-done:
+	default:
+		t.Error("the asynchronous invocation has not finished")
+	}
+}
+
+func TestServer_FunctionNotFound(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	_, err := s.Client().Invoke(context.Background(), &lambda.InvokeInput{
+		FunctionName: aws.String("function-name"),
+	})
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Errorf("want ResourceNotFoundException, got %v", err)
+	}
+}
+
+func TestServer_BufferedTransport(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle("function-name", func(ctx context.Context, req events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+		return events.LambdaFunctionURLResponse{
+			StatusCode: http.StatusCreated,
+			Headers: map[string]string{
+				"Content-Type": "text/plain",
+			},
+			Body: "hello " + req.RawPath,
+		}, nil
+	})
+
+	transport := lambtrip.NewBufferedTransport(s.Client())
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", got, "text/plain")
+	}
+	if string(body) != "hello /foo" {
+		t.Errorf("body = %q, want %q", string(body), "hello /foo")
+	}
+}
+
+func TestServer_ResponseStreamTransport(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle("function-name", StreamingHandlerFunc(func(ctx context.Context, payload []byte, w io.Writer) error {
+		io.WriteString(w, `{"statusCode":200,"headers":{"Content-Type":"text/plain"}}`)
+		w.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+		io.WriteString(w, "hello ")
+		io.WriteString(w, "world")
+		return nil
+	}))
+
+	transport := lambtrip.NewResponseStreamTransport(s.Client())
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", got, "text/plain")
+	}
+	if string(body) != "hello world" {
+		t.Errorf("body = %q, want %q", string(body), "hello world")
+	}
+}
+
+func TestServer_ResponseStreamError(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle("function-name", StreamingHandlerFunc(func(ctx context.Context, payload []byte, w io.Writer) error {
+		io.WriteString(w, `{"statusCode":200}`)
+		w.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+		io.WriteString(w, "hello")
+		return errors.New("something went wrong")
+	}))
+
+	transport := lambtrip.NewResponseStreamTransport(s.Client())
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+
+	var streamErr *lambtrip.ResponseStreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("want ResponseStreamError, got %v", err)
+	}
+	if !strings.Contains(streamErr.ErrorDetails, "something went wrong") {
+		t.Errorf("streamErr.ErrorDetails = %q, want %q", streamErr.ErrorDetails, "something went wrong")
+	}
+}
+
+func TestSplitFunctionName(t *testing.T) {
+	tests := []struct {
+		in        string
+		name      string
+		qualifier string
+	}{
+		{"my-function", "my-function", ""},
+		{"my-function:live", "my-function", "live"},
+		{"arn:aws:lambda:us-east-1:123456789012:function:my-function", "my-function", ""},
+		{"arn:aws:lambda:us-east-1:123456789012:function:my-function:1", "my-function", "1"},
+		{"123456789012:function:my-function", "my-function", ""},
+		{"123456789012:function:my-function:live", "my-function", "live"},
+	}
+	for _, tt := range tests {
+		name, qualifier := splitFunctionName(tt.in)
+		if name != tt.name || qualifier != tt.qualifier {
+			t.Errorf("splitFunctionName(%q) = %q, %q, want %q, %q", tt.in, name, qualifier, tt.name, tt.qualifier)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package lambtrip
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ConcurrencyLimitError is an error returned when the queue of the concurrency limiter is full.
+type ConcurrencyLimitError struct {
+	FunctionName string
+	Qualifier    string
+}
+
+func (e *ConcurrencyLimitError) Error() string {
+	if e.Qualifier == "" {
+		return fmt.Sprintf("lambtrip: too many requests waiting for the concurrency limit of %s", e.FunctionName)
+	}
+	return fmt.Sprintf("lambtrip: too many requests waiting for the concurrency limit of %s:%s", e.FunctionName, e.Qualifier)
+}
+
+// ConcurrencyLimiter caps the number of in-flight invocations per function name and qualifier.
+// Requests over the limit wait for a slot until their contexts are done.
+// It can be shared between transports to share the limit.
+type ConcurrencyLimiter struct {
+	// MaxConcurrency is the maximum number of in-flight invocations per function name and qualifier.
+	// If zero or negative, the number of invocations is not limited.
+	MaxConcurrency int
+
+	// MaxQueue is the maximum number of requests waiting for a slot per function name and qualifier.
+	// If the queue is full, the requests fail with ConcurrencyLimitError immediately.
+	// If zero or negative, the queue is unbounded.
+	MaxQueue int
+
+	mu    sync.Mutex
+	slots map[functionKey]*limiterSlot
+}
+
+// functionKey identifies the function by the function name and qualifier.
+type functionKey struct {
+	functionName string
+	qualifier    string
+}
+
+func newFunctionKey(functionName string, qualifier *string) functionKey {
+	key := functionKey{functionName: functionName}
+	if qualifier != nil {
+		key.qualifier = *qualifier
+	}
+	return key
+}
+
+type limiterSlot struct {
+	sem     chan struct{}
+	waiting int
+}
+
+// acquire waits for a slot of the function, and returns the function to release it.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, functionName string, qualifier *string) (release func(), err error) {
+	if l == nil || l.MaxConcurrency <= 0 {
+		return func() {}, nil
+	}
+	key := newFunctionKey(functionName, qualifier)
+
+	l.mu.Lock()
+	if l.slots == nil {
+		l.slots = make(map[functionKey]*limiterSlot)
+	}
+	slot, ok := l.slots[key]
+	if !ok {
+		slot = &limiterSlot{
+			sem: make(chan struct{}, l.MaxConcurrency),
+		}
+		l.slots[key] = slot
+	}
+
+	// fast path: there is a free slot.
+	select {
+	case slot.sem <- struct{}{}:
+		l.mu.Unlock()
+		return slot.release, nil
+	default:
+	}
+
+	if l.MaxQueue > 0 && slot.waiting >= l.MaxQueue {
+		l.mu.Unlock()
+		return nil, &ConcurrencyLimitError{
+			FunctionName: key.functionName,
+			Qualifier:    key.qualifier,
+		}
+	}
+	slot.waiting++
+	l.mu.Unlock()
+
+	// slow path: wait for a slot until the context is done.
+	defer func() {
+		l.mu.Lock()
+		slot.waiting--
+		l.mu.Unlock()
+	}()
+	select {
+	case slot.sem <- struct{}{}:
+		return slot.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *limiterSlot) release() {
+	<-s.sem
+}
@@ -0,0 +1,130 @@
+package lambtrip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	l := &ConcurrencyLimiter{
+		MaxConcurrency: 1,
+		MaxQueue:       1,
+	}
+	ctx := context.Background()
+
+	release1, err := l.acquire(ctx, "function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another function or qualifier has its own limit.
+	release2, err := l.acquire(ctx, "function-name", aws.String("live"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release2()
+
+	// the second request waits in the queue.
+	done := make(chan error, 1)
+	go func() {
+		release, err := l.acquire(ctx, "function-name", nil)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	for {
+		l.mu.Lock()
+		waiting := l.slots[functionKey{functionName: "function-name"}].waiting
+		l.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the queue is full.
+	_, err = l.acquire(ctx, "function-name", nil)
+	var limitErr *ConcurrencyLimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("want ConcurrencyLimitError, got %v", err)
+	}
+
+	release1()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrencyLimiter_Deadline(t *testing.T) {
+	l := &ConcurrencyLimiter{
+		MaxConcurrency: 1,
+	}
+	release, err := l.acquire(context.Background(), "function-name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, "function-name", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestBufferedTransport_Limiter(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	transport := &BufferedTransport{
+		Limiter: &ConcurrencyLimiter{
+			MaxConcurrency: 2,
+		},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo/bar", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if got := maxInFlight.Load(); got > 2 {
+		t.Errorf("max in-flight invocations = %d, want <= %d", got, 2)
+	}
+}
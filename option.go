@@ -0,0 +1,160 @@
+package lambtrip
+
+import (
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Option configures the transports.
+// The options are shared by NewBufferedTransport, NewResponseStreamTransport, NewHandlerTransport,
+// NewLocalBufferedTransport, and NewLocalResponseStreamTransport.
+// The options that a transport does not support are ignored.
+// They set the exported fields of the transports, so the fields can still be changed after the construction.
+type Option func(o *options)
+
+type options struct {
+	errorResponse        bool
+	codec                EventCodec
+	invocationType       types.InvocationType
+	invocationTypeHeader bool
+	logType              types.LogType
+	clientContextFunc    func(req *http.Request) (*ClientContext, error)
+	trustedProxies       []netip.Prefix
+	authorizerFunc       func(req *http.Request) (*Authorizer, error)
+	binaryDetector       func(h http.Header) bool
+	defaultContentType   string
+	protocol             string
+	qualifierFunc        func(req *http.Request) (*string, error)
+	retry                *RetryPolicy
+	limiter              *ConcurrencyLimiter
+	circuitBreaker       *CircuitBreaker
+	timeout              time.Duration
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithErrorResponse sets ErrorResponse of the transports.
+// If enabled, RoundTrip returns a 502 Bad Gateway response instead of an error when the function fails,
+// in the same way as Lambda Function URLs.
+func WithErrorResponse(enabled bool) Option {
+	return func(o *options) {
+		o.errorResponse = enabled
+	}
+}
+
+// WithCodec sets Codec of the transports.
+// The codec decides the event format, e.g. the version of the payload and the mapping of the responses.
+func WithCodec(codec EventCodec) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// WithInvocationType sets InvocationType of BufferedTransport.
+func WithInvocationType(invocationType types.InvocationType) Option {
+	return func(o *options) {
+		o.invocationType = invocationType
+	}
+}
+
+// WithInvocationTypeHeader sets InvocationTypeHeader of BufferedTransport.
+// If enabled, the X-Amz-Invocation-Type header of the request overrides the invocation type.
+func WithInvocationTypeHeader(enabled bool) Option {
+	return func(o *options) {
+		o.invocationTypeHeader = enabled
+	}
+}
+
+// WithLogType sets LogType of BufferedTransport.
+func WithLogType(logType types.LogType) Option {
+	return func(o *options) {
+		o.logType = logType
+	}
+}
+
+// WithClientContextFunc sets ClientContextFunc of the transports.
+func WithClientContextFunc(fn func(req *http.Request) (*ClientContext, error)) Option {
+	return func(o *options) {
+		o.clientContextFunc = fn
+	}
+}
+
+// WithTrustedProxies sets TrustedProxies of the transports.
+func WithTrustedProxies(prefixes ...netip.Prefix) Option {
+	return func(o *options) {
+		o.trustedProxies = prefixes
+	}
+}
+
+// WithAuthorizerFunc sets AuthorizerFunc of the transports.
+func WithAuthorizerFunc(fn func(req *http.Request) (*Authorizer, error)) Option {
+	return func(o *options) {
+		o.authorizerFunc = fn
+	}
+}
+
+// WithBinaryDetector sets BinaryDetector of the transports.
+// fn reports whether the request body is binary by the headers.
+func WithBinaryDetector(fn func(h http.Header) bool) Option {
+	return func(o *options) {
+		o.binaryDetector = fn
+	}
+}
+
+// WithDefaultContentType sets DefaultContentType of the transports.
+func WithDefaultContentType(contentType string) Option {
+	return func(o *options) {
+		o.defaultContentType = contentType
+	}
+}
+
+// WithProtocol sets Protocol of the transports.
+func WithProtocol(protocol string) Option {
+	return func(o *options) {
+		o.protocol = protocol
+	}
+}
+
+// WithQualifierFunc sets QualifierFunc of the transports.
+func WithQualifierFunc(fn func(req *http.Request) (*string, error)) Option {
+	return func(o *options) {
+		o.qualifierFunc = fn
+	}
+}
+
+// WithRetry sets Retry of BufferedTransport.
+func WithRetry(policy *RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// WithLimiter sets Limiter of BufferedTransport and ResponseStreamTransport.
+func WithLimiter(limiter *ConcurrencyLimiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithCircuitBreaker sets CircuitBreaker of BufferedTransport and ResponseStreamTransport.
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(o *options) {
+		o.circuitBreaker = breaker
+	}
+}
+
+// WithTimeout sets Timeout of HandlerTransport.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
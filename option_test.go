@@ -0,0 +1,123 @@
+package lambtrip
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestNewBufferedTransport_Options(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	retry := &RetryPolicy{}
+	limiter := &ConcurrencyLimiter{}
+	breaker := &CircuitBreaker{}
+	transport := NewBufferedTransport(
+		InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			return nil, nil
+		}),
+		WithErrorResponse(true),
+		WithCodec(EventFormatALB),
+		WithInvocationType(types.InvocationTypeEvent),
+		WithInvocationTypeHeader(true),
+		WithLogType(types.LogTypeTail),
+		WithTrustedProxies(proxies...),
+		WithRetry(retry),
+		WithLimiter(limiter),
+		WithCircuitBreaker(breaker),
+	)
+
+	if !transport.ErrorResponse {
+		t.Error("ErrorResponse is false, want true")
+	}
+	if transport.Codec != EventFormatALB {
+		t.Errorf("Codec = %v, want %v", transport.Codec, EventFormatALB)
+	}
+	if transport.InvocationType != types.InvocationTypeEvent {
+		t.Errorf("InvocationType = %q, want %q", transport.InvocationType, types.InvocationTypeEvent)
+	}
+	if !transport.InvocationTypeHeader {
+		t.Error("InvocationTypeHeader is false, want true")
+	}
+	if transport.LogType != types.LogTypeTail {
+		t.Errorf("LogType = %q, want %q", transport.LogType, types.LogTypeTail)
+	}
+	if len(transport.TrustedProxies) != 1 || transport.TrustedProxies[0] != proxies[0] {
+		t.Errorf("TrustedProxies = %v, want %v", transport.TrustedProxies, proxies)
+	}
+	if transport.Retry != retry {
+		t.Error("Retry is not set")
+	}
+	if transport.Limiter != limiter {
+		t.Error("Limiter is not set")
+	}
+	if transport.CircuitBreaker != breaker {
+		t.Error("CircuitBreaker is not set")
+	}
+}
+
+func TestNewResponseStreamTransport_Options(t *testing.T) {
+	limiter := &ConcurrencyLimiter{}
+	authorizer := func(req *http.Request) (*Authorizer, error) {
+		return &Authorizer{Lambda: map[string]any{"foo": "bar"}}, nil
+	}
+	transport := NewResponseStreamTransport(
+		nil,
+		WithErrorResponse(true),
+		WithLimiter(limiter),
+		WithAuthorizerFunc(authorizer),
+
+		// not supported by ResponseStreamTransport; ignored.
+		WithRetry(&RetryPolicy{}),
+	)
+
+	if !transport.ErrorResponse {
+		t.Error("ErrorResponse is false, want true")
+	}
+	if transport.Limiter != limiter {
+		t.Error("Limiter is not set")
+	}
+	if transport.AuthorizerFunc == nil {
+		t.Error("AuthorizerFunc is not set")
+	}
+	if transport.lambda == nil {
+		t.Error("lambda is not set")
+	}
+}
+
+func TestNewHandlerTransport_Options(t *testing.T) {
+	transport := NewHandlerTransport(
+		func(ctx context.Context, payload []byte) ([]byte, error) {
+			return payload, nil
+		},
+		WithErrorResponse(true),
+		WithTimeout(time.Second),
+		WithBinaryDetector(func(h http.Header) bool { return false }),
+		WithDefaultContentType("text/plain"),
+		WithProtocol("HTTP/2.0"),
+		WithQualifierFunc(func(req *http.Request) (*string, error) { return nil, nil }),
+	)
+
+	if !transport.ErrorResponse {
+		t.Error("ErrorResponse is false, want true")
+	}
+	if transport.BinaryDetector == nil {
+		t.Error("BinaryDetector is not set")
+	}
+	if transport.DefaultContentType != "text/plain" {
+		t.Errorf("DefaultContentType = %q, want %q", transport.DefaultContentType, "text/plain")
+	}
+	if transport.Protocol != "HTTP/2.0" {
+		t.Errorf("Protocol = %q, want %q", transport.Protocol, "HTTP/2.0")
+	}
+	if transport.QualifierFunc == nil {
+		t.Error("QualifierFunc is not set")
+	}
+	if transport.Timeout != time.Second {
+		t.Errorf("Timeout = %v, want %v", transport.Timeout, time.Second)
+	}
+}
@@ -0,0 +1,70 @@
+package lambtrip
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+const (
+	// maxPayloadSize is the maximum size of the payload of synchronous invocations.
+	maxPayloadSize = 6 * 1024 * 1024
+
+	// maxAsyncPayloadSize is the maximum size of the payload of asynchronous invocations.
+	maxAsyncPayloadSize = 1024 * 1024
+)
+
+// PayloadTooLargeError is an error returned when the encoded event exceeds the payload size limit of Lambda.
+// The function is not invoked.
+type PayloadTooLargeError struct {
+	// Size is the size of the encoded event in bytes.
+	// It may be an estimate computed from Content-Length of the request.
+	Size int64
+
+	// Limit is the payload size limit in bytes.
+	Limit int64
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("lambtrip: payload size %d bytes exceeds the limit of %d bytes", e.Size, e.Limit)
+}
+
+// checkContentLength fails fast if the body of req obviously exceeds the payload size limit.
+// The size of binary bodies is computed after base64 encoding.
+func checkContentLength(req *http.Request, invocationType types.InvocationType) error {
+	size := req.ContentLength
+	if size <= 0 {
+		return nil
+	}
+	if isBinaryRequest(req) {
+		size = int64(base64.StdEncoding.EncodedLen(int(size)))
+	}
+	if limit := payloadLimit(invocationType); size > limit {
+		return &PayloadTooLargeError{
+			Size:  size,
+			Limit: limit,
+		}
+	}
+	return nil
+}
+
+// checkPayloadSize checks the size of the encoded event.
+func checkPayloadSize(payload []byte, invocationType types.InvocationType) error {
+	if limit := payloadLimit(invocationType); int64(len(payload)) > limit {
+		return &PayloadTooLargeError{
+			Size:  int64(len(payload)),
+			Limit: limit,
+		}
+	}
+	return nil
+}
+
+// payloadLimit returns the payload size limit of the invocation type.
+func payloadLimit(invocationType types.InvocationType) int64 {
+	if invocationType == types.InvocationTypeEvent {
+		return maxAsyncPayloadSize
+	}
+	return maxPayloadSize
+}
@@ -0,0 +1,111 @@
+package lambtrip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestBufferedTransport_PayloadTooLarge(t *testing.T) {
+	transport := &BufferedTransport{
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			t.Error("the function must not be invoked")
+			return nil, errors.New("unexpected invocation")
+		}),
+	}
+
+	tests := []struct {
+		name        string
+		size        int
+		contentType string
+	}{
+		{
+			name:        "text",
+			size:        maxPayloadSize + 1,
+			contentType: "text/plain",
+		},
+		{
+			// 5 MB of binary is encoded in about 6.7 MB of base64.
+			name:        "binary",
+			size:        5 * 1024 * 1024,
+			contentType: "application/octet-stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.Repeat([]byte("a"), tt.size)
+			req, err := http.NewRequest(http.MethodPost, "lambda://function-name/foo/bar", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+			_, err = transport.RoundTrip(req)
+			var tooLarge *PayloadTooLargeError
+			if !errors.As(err, &tooLarge) {
+				t.Fatalf("want PayloadTooLargeError, got %v", err)
+			}
+			if tooLarge.Limit != maxPayloadSize {
+				t.Errorf("tooLarge.Limit = %d, want %d", tooLarge.Limit, maxPayloadSize)
+			}
+		})
+	}
+}
+
+func TestBufferedTransport_PayloadTooLargeEvent(t *testing.T) {
+	transport := &BufferedTransport{
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			t.Error("the function must not be invoked")
+			return nil, errors.New("unexpected invocation")
+		}),
+		InvocationType: types.InvocationTypeEvent,
+	}
+
+	// the body fits in the synchronous limit, but not in the asynchronous limit.
+	body := bytes.Repeat([]byte("a"), maxAsyncPayloadSize+1)
+	req, err := http.NewRequest(http.MethodPost, "lambda://function-name/foo/bar", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	_, err = transport.RoundTrip(req)
+	var tooLarge *PayloadTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("want PayloadTooLargeError, got %v", err)
+	}
+	if tooLarge.Limit != maxAsyncPayloadSize {
+		t.Errorf("tooLarge.Limit = %d, want %d", tooLarge.Limit, maxAsyncPayloadSize)
+	}
+}
+
+func TestBufferedTransport_PayloadTooLargeUnknownLength(t *testing.T) {
+	transport := &BufferedTransport{
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			t.Error("the function must not be invoked")
+			return nil, errors.New("unexpected invocation")
+		}),
+	}
+
+	body := bytes.Repeat([]byte("a"), maxPayloadSize)
+	req, err := http.NewRequest(http.MethodPost, "lambda://function-name/foo/bar", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "text/plain")
+
+	// the body itself fits, but the encoded event doesn't.
+	_, err = transport.RoundTrip(req)
+	var tooLarge *PayloadTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("want PayloadTooLargeError, got %v", err)
+	}
+	if tooLarge.Size <= maxPayloadSize {
+		t.Errorf("tooLarge.Size = %d, want > %d", tooLarge.Size, maxPayloadSize)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
 )
@@ -26,6 +29,14 @@ func (e *ResponseStreamError) Error() string {
 var separate = []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
 
 var _ streamGetter = (*lambda.InvokeWithResponseStreamOutput)(nil)
+var _ InvokeWithResponseStreamAPIClient = (*lambda.Client)(nil)
+
+// InvokeWithResponseStreamAPIClient is a client for the InvokeWithResponseStream API of AWS Lambda.
+// *lambda.Client implements it.
+// It can be implemented by wrappers of the client, e.g. for caching, metrics, or cross-account clients.
+type InvokeWithResponseStreamAPIClient interface {
+	InvokeWithResponseStream(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*lambda.InvokeWithResponseStreamOutput, error)
+}
 
 type streamGetter interface {
 	GetStream() *lambda.InvokeWithResponseStreamEventStream
@@ -39,18 +50,96 @@ type invokeWithResponseStreamOutput struct {
 var _ http.RoundTripper = (*ResponseStreamTransport)(nil)
 
 type ResponseStreamTransport struct {
+	// ErrorResponse, if true, makes RoundTrip return a 502 Bad Gateway response instead of an error
+	// when the function fails before sending the response prelude, or sends a malformed prelude,
+	// in the same way as Lambda Function URLs.
+	ErrorResponse bool
+
+	// Codec converts requests into events.
+	// If nil, EventFormatFunctionURL is used.
+	Codec EventCodec
+
+	// ClientContextFunc returns the client context passed to the function.
+	// If nil, the client context attached to the request context by WithClientContext is used.
+	ClientContextFunc func(req *http.Request) (*ClientContext, error)
+
+	// TrustedProxies is the list of networks of the trusted proxies.
+	// If the peer of the request is in them, the source IP address of the event is taken from
+	// the Forwarded header or the X-Forwarded-For header instead of RemoteAddr of the request.
+	TrustedProxies []netip.Prefix
+
+	// AuthorizerFunc returns the authorizer context sent to the function.
+	// If it returns an error wrapping ErrUnauthorized, RoundTrip returns 401 Unauthorized
+	// without invoking the function.
+	// If nil, the authorizer context attached to the request context by WithAuthorizer is used.
+	AuthorizerFunc func(req *http.Request) (*Authorizer, error)
+
+	// BinaryDetector reports whether the request body is binary by the headers.
+	// The binary bodies are sent in base64.
+	// If nil, the bodies are binary unless they have text types, such as text/* and application/json,
+	// and no Content-Encoding.
+	BinaryDetector func(h http.Header) bool
+
+	// DefaultContentType is the Content-Type of the responses that don't specify it.
+	// If empty, "application/json" is used.
+	DefaultContentType string
+
+	// Protocol is the protocol sent in the event, such as "HTTP/1.1".
+	// If empty, the protocol of the request is used.
+	Protocol string
+
+	// QualifierFunc returns the function qualifier of the request.
+	// If nil, the user information of the URL is used, e.g. lambda://alias@function-name/foo/bar.
+	QualifierFunc func(req *http.Request) (*string, error)
+
+	// Limiter caps the number of in-flight invocations per function name and qualifier.
+	// The slot is held until the response body is closed.
+	// If nil, the number of invocations is not limited.
+	Limiter *ConcurrencyLimiter
+
+	// CircuitBreaker stops invoking the functions that are persistently failing.
+	// The failures after the response prelude are not counted.
+	// If nil, the functions are always invoked.
+	CircuitBreaker *CircuitBreaker
+
 	lambda func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error)
 }
 
-func NewResponseStreamTransport(c *lambda.Client) *ResponseStreamTransport {
+// NewResponseStreamTransport returns a new ResponseStreamTransport that invokes the functions with c.
+func NewResponseStreamTransport(c InvokeWithResponseStreamAPIClient, opts ...Option) *ResponseStreamTransport {
+	t := newResponseStreamTransport(opts)
+	t.lambda = func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
+		out, err := c.InvokeWithResponseStream(ctx, params, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		return &invokeWithResponseStreamOutput{Output: out, StreamGetter: out}, nil
+	}
+	return t
+}
+
+func newResponseStreamTransport(opts []Option) *ResponseStreamTransport {
+	o := newOptions(opts)
 	return &ResponseStreamTransport{
-		lambda: func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
-			out, err := c.InvokeWithResponseStream(ctx, params, optFns...)
-			if err != nil {
-				return nil, err
-			}
-			return &invokeWithResponseStreamOutput{Output: out, StreamGetter: out}, nil
-		},
+		ErrorResponse:      o.errorResponse,
+		Codec:              o.codec,
+		ClientContextFunc:  o.clientContextFunc,
+		TrustedProxies:     o.trustedProxies,
+		AuthorizerFunc:     o.authorizerFunc,
+		BinaryDetector:     o.binaryDetector,
+		DefaultContentType: o.defaultContentType,
+		Protocol:           o.protocol,
+		QualifierFunc:      o.qualifierFunc,
+		Limiter:            o.limiter,
+		CircuitBreaker:     o.circuitBreaker,
+	}
+}
+
+func (t *ResponseStreamTransport) eventOptions() eventOptions {
+	return eventOptions{
+		binaryDetector:     t.BinaryDetector,
+		defaultContentType: t.DefaultContentType,
+		protocol:           t.Protocol,
 	}
 }
 
@@ -58,21 +147,55 @@ func (t *ResponseStreamTransport) RoundTrip(req *http.Request) (*http.Response,
 	ctx := req.Context()
 
 	// build the request
-	r, err := buildRequest(req)
+	codec := codecOrDefault(t.Codec)
+	if f, ok := codec.(EventFormat); ok && !f.supportsResponseStreaming() {
+		return nil, fmt.Errorf("lambtrip: event format %q does not support response streaming", f)
+	}
+	eventReq, err := authorize(t.AuthorizerFunc, withEventOptions(withSourceIP(req, t.TrustedProxies), t.eventOptions()))
+	if errors.Is(err, ErrUnauthorized) {
+		return newUnauthorizedResponse(req), nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	payload, err := json.Marshal(r)
+	if err := checkContentLength(eventReq, types.InvocationTypeRequestResponse); err != nil {
+		return nil, err
+	}
+	payload, err := codec.EncodeRequest(eventReq)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkPayloadSize(payload, types.InvocationTypeRequestResponse); err != nil {
+		return nil, err
+	}
 
 	// invoke the lambda
+	qualifier, err := requestQualifier(t.QualifierFunc, req)
+	if err != nil {
+		return nil, err
+	}
+	clientContext, err := encodeClientContext(t.ClientContextFunc, req)
+	if err != nil {
+		return nil, err
+	}
+	done, err := t.CircuitBreaker.allow(req.URL.Host, qualifier)
+	if err != nil {
+		return nil, err
+	}
+	release, err := t.Limiter.acquire(ctx, req.URL.Host, qualifier)
+	if err != nil {
+		done(nil, err)
+		return nil, err
+	}
 	out, err := t.lambda(ctx, &lambda.InvokeWithResponseStreamInput{
-		FunctionName: aws.String(req.URL.Host),
-		Payload:      payload,
+		FunctionName:  aws.String(req.URL.Host),
+		Qualifier:     qualifier,
+		ClientContext: clientContext,
+		Payload:       payload,
 	})
 	if err != nil {
+		release()
+		done(nil, err)
 		return nil, err
 	}
 	stream := out.StreamGetter.GetStream()
@@ -80,21 +203,32 @@ func (t *ResponseStreamTransport) RoundTrip(req *http.Request) (*http.Response,
 	// handle the http-integration-response
 	resp, buf, err := handleStreamingPrelude(ctx, stream)
 	if err != nil {
+		release()
+		done(nil, err)
+		if t.ErrorResponse && ctx.Err() == nil {
+			var requestID string
+			if out.Output != nil {
+				requestID, _ = awsmiddleware.GetRequestIDMetadata(out.Output.ResultMetadata)
+			}
+			return newErrorResponse(req, requestID, err), nil
+		}
 		return nil, err
 	}
 
-	return &http.Response{
+	httpResp := &http.Response{
 		Status:        resp.status(),
 		StatusCode:    resp.statusCode(),
 		Proto:         "HTTP/1.0",
 		ProtoMajor:    1,
 		ProtoMinor:    0,
-		Header:        resp.header(),
+		Header:        resp.header(defaultContentType(eventReq)),
 		ContentLength: -1,
-		Body:          &streamingBody{ctx, buf, stream},
+		Body:          &streamingBody{ctx: ctx, buf: buf, stream: stream, release: release},
 		Close:         true,
 		Request:       req,
-	}, nil
+	}
+	done(httpResp, nil)
+	return httpResp, nil
 }
 
 func handleStreamingPrelude(ctx context.Context, stream *lambda.InvokeWithResponseStreamEventStream) (*response, []byte, error) {
@@ -150,9 +284,10 @@ var _ io.ReadCloser = (*streamingBody)(nil)
 var _ io.WriterTo = (*streamingBody)(nil)
 
 type streamingBody struct {
-	ctx    context.Context
-	buf    []byte
-	stream *lambda.InvokeWithResponseStreamEventStream
+	ctx     context.Context
+	buf     []byte
+	stream  *lambda.InvokeWithResponseStreamEventStream
+	release func()
 }
 
 func (b *streamingBody) Read(p []byte) (int, error) {
@@ -244,5 +379,9 @@ LOOP:
 }
 
 func (b *streamingBody) Close() error {
+	if b.release != nil {
+		b.release()
+		b.release = nil
+	}
 	return b.stream.Close()
 }
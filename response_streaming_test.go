@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/shogo82148/lambtrip/lambtriptest"
 )
 
 var _ streamGetter = GetStreamMock(nil)
@@ -379,3 +380,146 @@ func TestTransport_ErrorDuringResponse(t *testing.T) {
 		t.Errorf("e.ErrorDetails = %q, want %q", e.ErrorDetails, "error message")
 	}
 }
+
+func TestTransportWithQualifier(t *testing.T) {
+	transport := &ResponseStreamTransport{
+		lambda: func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
+			return &invokeWithResponseStreamOutput{
+				Output: &lambda.InvokeWithResponseStreamOutput{
+					StatusCode:                http.StatusOK,
+					ResponseStreamContentType: aws.String("application/vnd.awslambda.http-integration-response"),
+				},
+				StreamGetter: GetStreamMock(func() *lambda.InvokeWithResponseStreamEventStream {
+					stream := lambda.NewInvokeWithResponseStreamEventStream()
+					stream.Reader = newInvokeWithResponseStreamResponseEventReader([][]byte{
+						[]byte(`{}`),
+						{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+						[]byte(`"` + aws.ToString(params.Qualifier) + `"`),
+					})
+					return stream
+				}),
+			}, nil
+		},
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://my-alias@function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `"my-alias"` {
+		t.Errorf("body = %q, want %q", body, `"my-alias"`)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransportWithInvalidQualifier(t *testing.T) {
+	transport := &ResponseStreamTransport{
+		lambda: func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
+			t.Error("the lambda function should not be invoked")
+			return nil, errors.New("unexpected invocation")
+		},
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://my%20alias@function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestTransport_ErrorResponse(t *testing.T) {
+	transport := &ResponseStreamTransport{
+		ErrorResponse: true,
+		lambda: func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
+			return &invokeWithResponseStreamOutput{
+				Output: &lambda.InvokeWithResponseStreamOutput{
+					StatusCode:                http.StatusOK,
+					ResponseStreamContentType: aws.String("application/vnd.awslambda.http-integration-response"),
+				},
+				StreamGetter: GetStreamMock(func() *lambda.InvokeWithResponseStreamEventStream {
+					completeEvent := types.InvokeWithResponseStreamCompleteEvent{
+						ErrorCode:    aws.String("Sandbox.Timedout"),
+						ErrorDetails: aws.String("Task timed out after 3.00 seconds"),
+					}
+					stream := lambda.NewInvokeWithResponseStreamEventStream()
+					stream.Reader = newInvokeWithResponseStreamResponseEventReaderWithCustomCompleteEvent(nil, completeEvent)
+					return stream
+				}),
+			}, nil
+		},
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+	if resp.Header.Get("x-amzn-ErrorType") != "Sandbox.Timedout" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "x-amzn-ErrorType", resp.Header.Get("x-amzn-ErrorType"), "Sandbox.Timedout")
+	}
+}
+
+type countingStreamClient struct {
+	InvokeWithResponseStreamAPIClient
+	calls int
+}
+
+func (c *countingStreamClient) InvokeWithResponseStream(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*lambda.InvokeWithResponseStreamOutput, error) {
+	c.calls++
+	return c.InvokeWithResponseStreamAPIClient.InvokeWithResponseStream(ctx, params, optFns...)
+}
+
+func TestNewResponseStreamTransport_InvokeWithResponseStreamAPIClient(t *testing.T) {
+	s := lambtriptest.NewServer()
+	defer s.Close()
+	s.Handle("function-name", func(ctx context.Context, payload []byte) ([]byte, error) {
+		return []byte("{\"statusCode\":200}\x00\x00\x00\x00\x00\x00\x00\x00Hello"), nil
+	})
+
+	client := &countingStreamClient{InvokeWithResponseStreamAPIClient: s.Client()}
+	transport := NewResponseStreamTransport(client)
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "Hello" {
+		t.Errorf("body = %q, want %q", string(body), "Hello")
+	}
+	if client.calls != 1 {
+		t.Errorf("client.calls = %d, want %d", client.calls, 1)
+	}
+}
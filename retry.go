@@ -0,0 +1,186 @@
+package lambtrip
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBaseDelay   = 100 * time.Millisecond
+	defaultRetryMaxDelay    = 20 * time.Second
+)
+
+// RetryPolicy is the policy to retry invocations that fail with throttling or transient errors,
+// such as TooManyRequestsException, ServiceException, and EC2ThrottledException.
+// By default, only the requests with idempotent methods are retried,
+// because the function may have run before the failure, e.g. ServiceException.
+// The requests with the Idempotency-Key or X-Idempotency-Key header are also considered idempotent,
+// in the same way as net/http.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	// If zero, 3 is used.
+	MaxAttempts int
+
+	// BaseDelay is the base delay of the exponential backoff.
+	// If zero, 100 milliseconds is used.
+	BaseDelay time.Duration
+
+	// MaxDelay is the upper limit of the delay.
+	// If zero, 20 seconds is used.
+	MaxDelay time.Duration
+
+	// RetryNonIdempotent, if true, retries the requests with non-idempotent methods, such as POST and PATCH.
+	// The function may run more than once for the same request.
+	RetryNonIdempotent bool
+
+	// Metrics, if not nil, records the number of the retries.
+	Metrics *RetryMetrics
+}
+
+// RetryMetrics records the number of the retries.
+// It is safe for concurrent use.
+type RetryMetrics struct {
+	retries   atomic.Int64
+	exhausted atomic.Int64
+}
+
+// Retries returns the total number of the retries.
+func (m *RetryMetrics) Retries() int64 {
+	return m.retries.Load()
+}
+
+// Exhausted returns the number of the requests that failed after the maximum number of attempts.
+func (m *RetryMetrics) Exhausted() int64 {
+	return m.exhausted.Load()
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil {
+		return 1
+	}
+	if p.MaxAttempts <= 0 {
+		return defaultRetryMaxAttempts
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the delay before the attempt-th retry (starting from 1).
+// It uses the exponential backoff with full jitter.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+
+	delay := maxDelay
+	if attempt < 32 {
+		if d := base << (attempt - 1); d > 0 && d < maxDelay {
+			delay = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// retry invokes fn until it succeeds, fails with a non-retryable error, or reaches the maximum number of attempts.
+func (p *RetryPolicy) retry(req *http.Request, fn func() error) error {
+	maxAttempts := p.maxAttempts()
+	if p != nil && !p.RetryNonIdempotent && !isIdempotent(req) {
+		maxAttempts = 1
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		retryAfter, ok := isRetryable(err)
+		if !ok {
+			return err
+		}
+		if attempt >= maxAttempts {
+			if p != nil && p.Metrics != nil && maxAttempts > 1 {
+				p.Metrics.exhausted.Add(1)
+			}
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// the request would time out while waiting.
+			return err
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+		if p.Metrics != nil {
+			p.Metrics.retries.Add(1)
+		}
+	}
+}
+
+// isRetryable reports whether err is a throttling or transient error,
+// and returns the delay hinted by Lambda.
+func isRetryable(err error) (retryAfter time.Duration, ok bool) {
+	var tooManyRequests *types.TooManyRequestsException
+	if errors.As(err, &tooManyRequests) {
+		if sec, err := strconv.ParseFloat(aws.ToString(tooManyRequests.RetryAfterSeconds), 64); err == nil && sec > 0 {
+			retryAfter = time.Duration(sec * float64(time.Second))
+		}
+		return retryAfter, true
+	}
+
+	var serviceErr *types.ServiceException
+	if errors.As(err, &serviceErr) {
+		return 0, true
+	}
+
+	var ec2Throttled *types.EC2ThrottledException
+	if errors.As(err, &ec2Throttled) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// isIdempotent reports whether req is idempotent.
+// The body of req has already been read into the payload, so it is always replayable.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,173 @@
+package lambtrip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestBufferedTransport_Retry(t *testing.T) {
+	var attempts int
+	metrics := &RetryMetrics{}
+	transport := &BufferedTransport{
+		Retry: &RetryPolicy{
+			MaxAttempts:        3,
+			BaseDelay:          time.Millisecond,
+			RetryNonIdempotent: true,
+			Metrics:            metrics,
+		},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, &types.TooManyRequestsException{
+					Message:           aws.String("Rate Exceeded."),
+					RetryAfterSeconds: aws.String("0.001"),
+				}
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "lambda://function-name/foo/bar", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want %d", attempts, 3)
+	}
+	if got := metrics.Retries(); got != 2 {
+		t.Errorf("metrics.Retries() = %d, want %d", got, 2)
+	}
+	if got := metrics.Exhausted(); got != 0 {
+		t.Errorf("metrics.Exhausted() = %d, want %d", got, 0)
+	}
+}
+
+func TestBufferedTransport_RetryExhausted(t *testing.T) {
+	var attempts int
+	metrics := &RetryMetrics{}
+	transport := &BufferedTransport{
+		Retry: &RetryPolicy{
+			MaxAttempts: 2,
+			BaseDelay:   time.Millisecond,
+			Metrics:     metrics,
+		},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			attempts++
+			return nil, &types.ServiceException{}
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = transport.RoundTrip(req)
+	var serviceErr *types.ServiceException
+	if !errors.As(err, &serviceErr) {
+		t.Errorf("want ServiceException, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want %d", attempts, 2)
+	}
+	if got := metrics.Exhausted(); got != 1 {
+		t.Errorf("metrics.Exhausted() = %d, want %d", got, 1)
+	}
+}
+
+func TestBufferedTransport_RetryNonIdempotent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{
+			name: "POST",
+			want: 1,
+		},
+		{
+			name:   "Idempotency-Key",
+			header: http.Header{"Idempotency-Key": {"key"}},
+			want:   3,
+		},
+		{
+			name:   "X-Idempotency-Key",
+			header: http.Header{"X-Idempotency-Key": {"key"}},
+			want:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			transport := &BufferedTransport{
+				Retry: &RetryPolicy{
+					BaseDelay: time.Millisecond,
+				},
+				lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+					attempts++
+					return nil, &types.ServiceException{}
+				}),
+			}
+
+			// the body is replayable, but the method is not idempotent.
+			ctx := context.Background()
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, "lambda://function-name/foo/bar", strings.NewReader("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			if _, err := transport.RoundTrip(req); err == nil {
+				t.Error("want error, got nil")
+			}
+			if attempts != tt.want {
+				t.Errorf("attempts = %d, want %d", attempts, tt.want)
+			}
+		})
+	}
+}
+
+func TestBufferedTransport_RetryNonRetryableError(t *testing.T) {
+	var attempts int
+	transport := &BufferedTransport{
+		Retry: &RetryPolicy{
+			BaseDelay: time.Millisecond,
+		},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			attempts++
+			return nil, &types.ResourceNotFoundException{}
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("want error, got nil")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want %d", attempts, 1)
+	}
+}
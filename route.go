@@ -0,0 +1,151 @@
+package lambtrip
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// route is a parsed route of API Gateway HTTP APIs.
+type route struct {
+	method   string // empty means ANY
+	path     string
+	segments []string
+}
+
+// parseRoute parses the route template such as "/users/{id}", "GET /users/{id}" and "ANY /files/{proxy+}".
+func parseRoute(s string) route {
+	method, path, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		method, path = "", method
+	}
+	path = strings.TrimSpace(path)
+	if method == "ANY" {
+		method = ""
+	}
+	return route{
+		method:   method,
+		path:     path,
+		segments: strings.Split(strings.Trim(path, "/"), "/"),
+	}
+}
+
+// key returns the route key such as "GET /users/{id}".
+func (r route) key() string {
+	method := r.method
+	if method == "" {
+		method = "ANY"
+	}
+	return method + " " + r.path
+}
+
+// match matches the route against the request,
+// and returns the path parameters and the number of the literal segments.
+func (r route) match(req *http.Request) (params map[string]string, literals int, ok bool) {
+	if r.method != "" && r.method != req.Method {
+		return nil, 0, false
+	}
+
+	segments := strings.Split(strings.Trim(req.URL.EscapedPath(), "/"), "/")
+	for i, seg := range r.segments {
+		if name, ok := greedyParam(seg); ok {
+			if i != len(r.segments)-1 || i >= len(segments) || segments[i] == "" {
+				return nil, 0, false
+			}
+			value, err := url.PathUnescape(strings.Join(segments[i:], "/"))
+			if err != nil {
+				return nil, 0, false
+			}
+			params = setParam(params, name, value)
+			return params, literals, true
+		}
+
+		if i >= len(segments) {
+			return nil, 0, false
+		}
+		if name, ok := pathParam(seg); ok {
+			if segments[i] == "" {
+				return nil, 0, false
+			}
+			value, err := url.PathUnescape(segments[i])
+			if err != nil {
+				return nil, 0, false
+			}
+			params = setParam(params, name, value)
+			continue
+		}
+		if seg != segments[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+	if len(segments) != len(r.segments) {
+		return nil, 0, false
+	}
+	return params, literals, true
+}
+
+func (r route) isGreedy() bool {
+	_, ok := greedyParam(r.segments[len(r.segments)-1])
+	return ok
+}
+
+// pathParam returns the name of the path parameter such as "{id}".
+func pathParam(seg string) (string, bool) {
+	if len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}' && seg[len(seg)-2] != '+' {
+		return seg[1 : len(seg)-1], true
+	}
+	return "", false
+}
+
+// greedyParam returns the name of the greedy path parameter such as "{proxy+}".
+func greedyParam(seg string) (string, bool) {
+	if len(seg) > 3 && strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "+}") {
+		return seg[1 : len(seg)-2], true
+	}
+	return "", false
+}
+
+func setParam(params map[string]string, name, value string) map[string]string {
+	if params == nil {
+		params = make(map[string]string)
+	}
+	params[name] = value
+	return params
+}
+
+// matchRoute returns the route key and the path parameters of the most specific route that matches the request.
+// Routes with more literal segments take precedence, and greedy routes are evaluated last
+// in the same way as API Gateway HTTP APIs.
+// It returns "$default" if no route matches.
+func matchRoute(routes []string, req *http.Request) (routeKey string, params map[string]string) {
+	routeKey = "$default"
+	bestLiterals := -1
+	bestGreedy := true
+	bestAny := true
+	for _, s := range routes {
+		r := parseRoute(s)
+		p, literals, ok := r.match(req)
+		if !ok {
+			continue
+		}
+
+		greedy, anyMethod := r.isGreedy(), r.method == ""
+		better := false
+		switch {
+		case bestLiterals < 0:
+			better = true
+		case greedy != bestGreedy:
+			better = !greedy
+		case literals != bestLiterals:
+			better = literals > bestLiterals
+		case anyMethod != bestAny:
+			better = !anyMethod
+		}
+		if better {
+			routeKey, params = r.key(), p
+			bestLiterals, bestGreedy, bestAny = literals, greedy, anyMethod
+		}
+	}
+	return routeKey, params
+}
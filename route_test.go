@@ -0,0 +1,138 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestMatchRoute(t *testing.T) {
+	routes := []string{
+		"/users/{id}",
+		"GET /users/me",
+		"POST /users/{id}",
+		"/users/{id}/posts/{postId}",
+		"ANY /files/{proxy+}",
+	}
+	tests := []struct {
+		method   string
+		path     string
+		routeKey string
+		params   map[string]string
+	}{
+		{
+			method:   http.MethodGet,
+			path:     "/users/1234",
+			routeKey: "ANY /users/{id}",
+			params:   map[string]string{"id": "1234"},
+		},
+		{
+			method:   http.MethodPost,
+			path:     "/users/1234",
+			routeKey: "POST /users/{id}",
+			params:   map[string]string{"id": "1234"},
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/users/me",
+			routeKey: "GET /users/me",
+			params:   nil,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/users/hello%20world",
+			routeKey: "ANY /users/{id}",
+			params:   map[string]string{"id": "hello world"},
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/users/1234/posts/5678",
+			routeKey: "ANY /users/{id}/posts/{postId}",
+			params:   map[string]string{"id": "1234", "postId": "5678"},
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/files/foo/bar.txt",
+			routeKey: "ANY /files/{proxy+}",
+			params:   map[string]string{"proxy": "foo/bar.txt"},
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/files/",
+			routeKey: "$default",
+			params:   nil,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/users",
+			routeKey: "$default",
+			params:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "lambda://function-name"+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			routeKey, params := matchRoute(routes, req)
+			if routeKey != tt.routeKey {
+				t.Errorf("routeKey = %q, want %q", routeKey, tt.routeKey)
+			}
+			if !reflect.DeepEqual(params, tt.params) {
+				t.Errorf("params = %v, want %v", params, tt.params)
+			}
+		})
+	}
+}
+
+func TestBufferedTransport_Routes(t *testing.T) {
+	transport := &BufferedTransport{
+		Codec: FunctionURLCodec{
+			Routes:         []string{"GET /users/{id}"},
+			Stage:          "prod",
+			StageVariables: map[string]string{"env": "production"},
+		},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req request
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if req.RouteKey != "GET /users/{id}" {
+				t.Errorf("req.RouteKey = %q, want %q", req.RouteKey, "GET /users/{id}")
+			}
+			if req.RequestContext.RouteKey != "GET /users/{id}" {
+				t.Errorf("req.RequestContext.RouteKey = %q, want %q", req.RequestContext.RouteKey, "GET /users/{id}")
+			}
+			if req.RequestContext.Stage != "prod" {
+				t.Errorf("req.RequestContext.Stage = %q, want %q", req.RequestContext.Stage, "prod")
+			}
+			if got, want := req.PathParameters, map[string]string{"id": "1234"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("req.PathParameters = %v, want %v", got, want)
+			}
+			if got, want := req.StageVariables, map[string]string{"env": "production"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("req.StageVariables = %v, want %v", got, want)
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/users/1234", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
@@ -0,0 +1,898 @@
+package lambtrip
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	defaultRuntimeTimeout = 3 * time.Second
+
+	// runtimeAPIPrefix is the prefix of the paths of the Lambda Runtime API.
+	runtimeAPIPrefix = "/2018-06-01/runtime/"
+
+	runtimeRequestIDHeader     = "Lambda-Runtime-Aws-Request-Id"
+	runtimeDeadlineHeader      = "Lambda-Runtime-Deadline-Ms"
+	runtimeFunctionARNHeader   = "Lambda-Runtime-Invoked-Function-Arn"
+	runtimeClientContextHeader = "Lambda-Runtime-Client-Context"
+	runtimeErrorTypeHeader     = "Lambda-Runtime-Function-Error-Type"
+	runtimeErrorBodyTrailer    = "Lambda-Runtime-Function-Error-Body"
+
+	// streamingResponseContentType is the content type of the responses of streaming functions.
+	// The response consists of the prelude in JSON, 8 NUL bytes, and the body.
+	streamingResponseContentType = "application/vnd.awslambda.http-integration-response"
+)
+
+var errLocalRuntimeClosed = errors.New("lambtrip: local runtime is closed")
+
+var _ InvokeAPIClient = (*LocalRuntime)(nil)
+
+// LocalRuntime runs a function binary locally, such as bootstrap of the provided.al2023 runtime,
+// and serves it the Lambda Runtime API on a loopback port.
+// It is a backend of the transports that works without AWS.
+// Use NewLocalBufferedTransport and NewLocalResponseStreamTransport to create the transports.
+//
+// Like an execution environment of Lambda, it handles one invocation at a time.
+// The process starts on the first invocation,
+// and starts again on the next invocation after it exits or times out.
+// The response of a buffered function is converted into the response stream for ResponseStreamTransport,
+// and the response of a streaming function is converted into the JSON payload for BufferedTransport,
+// so both buffered and streaming functions work with both transports.
+type LocalRuntime struct {
+	// Timeout is the timeout of the function.
+	// If the function does not finish the invocation within it, the process is killed.
+	// If zero, 3 seconds is used.
+	Timeout time.Duration
+
+	// Env is the additional environment variables of the function in the form "key=value".
+	// The function also inherits the environment of the current process.
+	Env []string
+
+	// Stdout and Stderr are the standard output and the standard error of the function.
+	// If nil, os.Stderr is used.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	path     string
+	args     []string
+	listener net.Listener
+	server   *http.Server
+
+	// next passes the invocations to the function waiting on /invocation/next.
+	next chan *localInvocation
+
+	// sem allows only one invocation at a time.
+	sem chan struct{}
+
+	mu          sync.Mutex
+	proc        *localProcess
+	invocations map[string]*localInvocation
+	closed      bool
+}
+
+// NewLocalRuntime returns a new LocalRuntime that runs the function binary at path with args.
+// It starts the Runtime API server immediately, but the process starts on the first invocation.
+// The caller should call Close to stop them.
+func NewLocalRuntime(path string, args ...string) (*LocalRuntime, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &LocalRuntime{
+		path:        path,
+		args:        args,
+		listener:    l,
+		next:        make(chan *localInvocation),
+		sem:         make(chan struct{}, 1),
+		invocations: make(map[string]*localInvocation),
+	}
+	r.server = &http.Server{
+		Handler:           http.HandlerFunc(r.serveRuntimeAPI),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go r.server.Serve(l)
+	return r, nil
+}
+
+// NewLocalBufferedTransport returns a new BufferedTransport that invokes the function run by r.
+func NewLocalBufferedTransport(r *LocalRuntime, opts ...Option) *BufferedTransport {
+	return NewBufferedTransport(r, opts...)
+}
+
+// NewLocalResponseStreamTransport returns a new ResponseStreamTransport that invokes the function run by r.
+func NewLocalResponseStreamTransport(r *LocalRuntime, opts ...Option) *ResponseStreamTransport {
+	t := newResponseStreamTransport(opts)
+	t.lambda = r.invokeWithResponseStream
+	return t
+}
+
+// Addr returns the address of the Runtime API server, which is passed to the function via AWS_LAMBDA_RUNTIME_API.
+func (r *LocalRuntime) Addr() string {
+	return r.listener.Addr().String()
+}
+
+// Close kills the process and stops the Runtime API server.
+func (r *LocalRuntime) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	proc := r.proc
+	r.mu.Unlock()
+
+	if proc != nil {
+		proc.kill()
+	}
+	return r.server.Close()
+}
+
+// Invoke invokes the function in the same way as the Invoke API of Lambda.
+func (r *LocalRuntime) Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	switch params.InvocationType {
+	case types.InvocationTypeDryRun:
+		return &lambda.InvokeOutput{StatusCode: http.StatusNoContent}, nil
+	case types.InvocationTypeEvent:
+		// queue the invocation in background, and return without waiting for the previous invocation.
+		inv, err := newLocalInvocation(aws.ToString(params.FunctionName), params.Qualifier, params.ClientContext, params.Payload)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			if err := r.start(context.Background(), inv); err != nil {
+				return
+			}
+			res := <-inv.result
+			res.close()
+		}()
+		return &lambda.InvokeOutput{
+			StatusCode:     http.StatusAccepted,
+			ResultMetadata: inv.metadata(),
+		}, nil
+	}
+
+	inv, err := r.invoke(ctx, aws.ToString(params.FunctionName), params.Qualifier, params.ClientContext, params.Payload)
+	if err != nil {
+		return nil, err
+	}
+	res, err := inv.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload, functionError, err := res.readAll()
+	if err != nil {
+		return nil, err
+	}
+	if functionError == "" && res.contentType == streamingResponseContentType {
+		payload, err = bufferStreamingResponse(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := &lambda.InvokeOutput{
+		StatusCode:      http.StatusOK,
+		ExecutedVersion: aws.String("$LATEST"),
+		Payload:         payload,
+		ResultMetadata:  inv.metadata(),
+	}
+	if functionError != "" {
+		out.FunctionError = aws.String(functionError)
+	}
+	return out, nil
+}
+
+// invokeWithResponseStream invokes the function in the same way as the InvokeWithResponseStream API of Lambda.
+func (r *LocalRuntime) invokeWithResponseStream(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
+	inv, err := r.invoke(ctx, aws.ToString(params.FunctionName), params.Qualifier, params.ClientContext, params.Payload)
+	if err != nil {
+		return nil, err
+	}
+	res, err := inv.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if res.stream != nil && res.contentType != streamingResponseContentType {
+		res, err = streamBufferedResponse(res)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stream := lambda.NewInvokeWithResponseStreamEventStream()
+	stream.Reader = newLocalEventReader(res)
+	out := &lambda.InvokeWithResponseStreamOutput{
+		StatusCode:      http.StatusOK,
+		ExecutedVersion: aws.String("$LATEST"),
+		ResultMetadata:  inv.metadata(),
+	}
+	if res.contentType != "" {
+		out.ResponseStreamContentType = aws.String(res.contentType)
+	}
+	return &invokeWithResponseStreamOutput{
+		Output:       out,
+		StreamGetter: localStreamGetter{stream: stream},
+	}, nil
+}
+
+// invoke queues the invocation.
+// It waits for the previous invocation to finish.
+func (r *LocalRuntime) invoke(ctx context.Context, functionName string, qualifier, clientContext *string, payload []byte) (*localInvocation, error) {
+	inv, err := newLocalInvocation(functionName, qualifier, clientContext, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.start(ctx, inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// start waits for the previous invocation to finish, and passes inv to the function.
+func (r *LocalRuntime) start(ctx context.Context, inv *localInvocation) error {
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	proc, err := r.process(inv.functionName)
+	if err != nil {
+		<-r.sem
+		return err
+	}
+
+	inv.deadline = time.Now().Add(r.timeout())
+	r.mu.Lock()
+	r.invocations[inv.id] = inv
+	r.mu.Unlock()
+	go r.supervise(proc, inv)
+	return nil
+}
+
+// supervise waits for the function to finish the invocation.
+// It fails the invocation if the process exits or the invocation times out.
+func (r *LocalRuntime) supervise(proc *localProcess, inv *localInvocation) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.invocations, inv.id)
+		r.mu.Unlock()
+		<-r.sem
+	}()
+
+	timer := time.NewTimer(time.Until(inv.deadline))
+	defer timer.Stop()
+
+	// pass the invocation to the function.
+	select {
+	case r.next <- inv:
+	case <-proc.exited:
+		inv.fail(r.exitError(proc, inv))
+		return
+	case <-timer.C:
+		proc.kill()
+		inv.fail(r.timeoutError(inv))
+		return
+	}
+
+	// wait for the result.
+	select {
+	case <-inv.done:
+	case <-proc.exited:
+		inv.fail(r.exitError(proc, inv))
+	case <-timer.C:
+		proc.kill()
+		inv.fail(r.timeoutError(inv))
+	}
+}
+
+// process returns the running process, or starts a new one.
+func (r *LocalRuntime) process(functionName string) (*localProcess, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, errLocalRuntimeClosed
+	}
+	if r.proc != nil && !r.proc.hasExited() {
+		return r.proc, nil
+	}
+
+	cmd := exec.Command(r.path, r.args...)
+	cmd.Dir = filepath.Dir(r.path)
+	cmd.Env = append(os.Environ(),
+		"AWS_LAMBDA_RUNTIME_API="+r.Addr(),
+		"AWS_LAMBDA_FUNCTION_NAME="+functionName,
+		"AWS_LAMBDA_FUNCTION_VERSION=$LATEST",
+		"AWS_LAMBDA_FUNCTION_MEMORY_SIZE=128",
+		"LAMBDA_TASK_ROOT="+cmd.Dir,
+	)
+	cmd.Env = append(cmd.Env, r.Env...)
+	cmd.Stdout = writerOrStderr(r.Stdout)
+	cmd.Stderr = writerOrStderr(r.Stderr)
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("lambtrip: failed to start the function: %w", err)
+	}
+
+	proc := &localProcess{
+		cmd:    cmd,
+		exited: make(chan struct{}),
+	}
+	go func() {
+		proc.err = cmd.Wait()
+		close(proc.exited)
+	}()
+	r.proc = proc
+	return proc, nil
+}
+
+func (r *LocalRuntime) timeout() time.Duration {
+	if r.Timeout <= 0 {
+		return defaultRuntimeTimeout
+	}
+	return r.Timeout
+}
+
+// exitError returns the error payload of the invocation interrupted by the exit of the process.
+// If the function has reported an initialization error, it is returned instead.
+func (r *LocalRuntime) exitError(proc *localProcess, inv *localInvocation) []byte {
+	r.mu.Lock()
+	initErr := proc.initErr
+	r.mu.Unlock()
+	if initErr != nil {
+		return initErr
+	}
+	return marshalRuntimeError("Runtime.ExitError", fmt.Sprintf("RequestId: %s Error: Runtime exited with error: %v", inv.id, proc.err))
+}
+
+// timeoutError returns the error payload of the invocation that timed out.
+func (r *LocalRuntime) timeoutError(inv *localInvocation) []byte {
+	msg := fmt.Sprintf("%s %s Task timed out after %.2f seconds", time.Now().UTC().Format(time.RFC3339Nano), inv.id, r.timeout().Seconds())
+	return marshalRuntimeError("Sandbox.Timedout", msg)
+}
+
+// serveRuntimeAPI serves the Lambda Runtime API.
+// See https://docs.aws.amazon.com/lambda/latest/dg/runtimes-api.html
+func (r *LocalRuntime) serveRuntimeAPI(w http.ResponseWriter, req *http.Request) {
+	path, ok := strings.CutPrefix(req.URL.Path, runtimeAPIPrefix)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	switch {
+	case path == "invocation/next":
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		r.handleNext(w, req)
+	case path == "init/error":
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		r.handleInitError(w, req)
+	case strings.HasPrefix(path, "invocation/"):
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id, action, _ := strings.Cut(strings.TrimPrefix(path, "invocation/"), "/")
+		switch action {
+		case "response":
+			r.handleResponse(w, req, id)
+		case "error":
+			r.handleError(w, req, id)
+		default:
+			http.NotFound(w, req)
+		}
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// handleNext passes the next invocation to the function.
+func (r *LocalRuntime) handleNext(w http.ResponseWriter, req *http.Request) {
+	var inv *localInvocation
+	select {
+	case inv = <-r.next:
+	case <-req.Context().Done():
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set(runtimeRequestIDHeader, inv.id)
+	h.Set(runtimeDeadlineHeader, strconv.FormatInt(inv.deadline.UnixMilli(), 10))
+	h.Set(runtimeFunctionARNHeader, inv.functionARN)
+	if inv.clientContext != "" {
+		h.Set(runtimeClientContextHeader, inv.clientContext)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(inv.payload)
+}
+
+// handleResponse streams the response of the function to the caller.
+// The function reports errors during the stream by the trailers
+// Lambda-Runtime-Function-Error-Type and Lambda-Runtime-Function-Error-Body.
+func (r *LocalRuntime) handleResponse(w http.ResponseWriter, req *http.Request, id string) {
+	inv, ok := r.invocation(id)
+	if !ok {
+		writeRuntimeAPIError(w, http.StatusBadRequest, "InvalidRequestID", "Invalid request ID")
+		return
+	}
+
+	pr, pw := io.Pipe()
+	inv.mu.Lock()
+	inv.stream = pw
+	inv.mu.Unlock()
+	if !inv.respond(&localResult{stream: pr, contentType: req.Header.Get("Content-Type")}) {
+		writeRuntimeAPIError(w, http.StatusForbidden, "InvalidStateTransition", "State transition from Ready to ResponseSent failed")
+		return
+	}
+
+	_, err := io.Copy(pw, req.Body)
+	if errors.Is(err, io.ErrClosedPipe) {
+		// the caller has closed the response body; discard the rest.
+		_, err = io.Copy(io.Discard, req.Body)
+	}
+	if err == nil {
+		if errorType := req.Trailer.Get(runtimeErrorTypeHeader); errorType != "" {
+			err = &ResponseStreamError{
+				ErrorCode:    errorType,
+				ErrorDetails: decodeRuntimeErrorBody(req.Trailer.Get(runtimeErrorBodyTrailer)),
+			}
+		}
+	}
+	pw.CloseWithError(err)
+	inv.finish()
+
+	w.WriteHeader(http.StatusAccepted)
+	io.WriteString(w, `{"status":"OK"}`)
+}
+
+// handleError reports the error of the invocation to the caller.
+func (r *LocalRuntime) handleError(w http.ResponseWriter, req *http.Request, id string) {
+	inv, ok := r.invocation(id)
+	if !ok {
+		writeRuntimeAPIError(w, http.StatusBadRequest, "InvalidRequestID", "Invalid request ID")
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return
+	}
+
+	inv.fail(runtimeErrorPayload(req.Header.Get(runtimeErrorTypeHeader), body))
+	inv.finish()
+
+	w.WriteHeader(http.StatusAccepted)
+	io.WriteString(w, `{"status":"OK"}`)
+}
+
+// handleInitError records the initialization error of the function.
+// It is reported to the caller when the process exits.
+func (r *LocalRuntime) handleInitError(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	if r.proc != nil {
+		r.proc.initErr = runtimeErrorPayload(req.Header.Get(runtimeErrorTypeHeader), body)
+	}
+	r.mu.Unlock()
+
+	w.WriteHeader(http.StatusAccepted)
+	io.WriteString(w, `{"status":"OK"}`)
+}
+
+func (r *LocalRuntime) invocation(id string) (*localInvocation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inv, ok := r.invocations[id]
+	return inv, ok
+}
+
+type localProcess struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+
+	// err is the result of cmd.Wait. It is valid after exited is closed.
+	err error
+
+	// initErr is the payload of the initialization error. It is guarded by LocalRuntime.mu.
+	initErr []byte
+}
+
+func (p *localProcess) hasExited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill kills the process, and waits for it to exit.
+func (p *localProcess) kill() {
+	p.cmd.Process.Kill()
+	<-p.exited
+}
+
+type localInvocation struct {
+	id            string
+	functionName  string
+	functionARN   string
+	clientContext string
+	payload       []byte
+	deadline      time.Time
+
+	// result receives exactly one result of the invocation.
+	result chan *localResult
+
+	// done is closed when the function finishes the invocation.
+	done     chan struct{}
+	doneOnce sync.Once
+
+	mu        sync.Mutex
+	responded bool
+	stream    *io.PipeWriter
+}
+
+type localResult struct {
+	// payload is the error payload if the invocation failed.
+	payload []byte
+
+	// stream is the response of the function if the invocation succeeded.
+	stream      io.ReadCloser
+	contentType string
+}
+
+func newLocalInvocation(functionName string, qualifier, clientContext *string, payload []byte) (*localInvocation, error) {
+	var cc []byte
+	if clientContext != nil {
+		var err error
+		cc, err = base64.StdEncoding.DecodeString(*clientContext)
+		if err != nil {
+			return nil, fmt.Errorf("lambtrip: failed to decode the client context: %w", err)
+		}
+	}
+	requestID, err := newRequestID()
+	if err != nil {
+		return nil, err
+	}
+	functionARN := defaultFunctionARN + functionName
+	if qualifier != nil {
+		functionARN += ":" + *qualifier
+	}
+	return &localInvocation{
+		id:            requestID,
+		functionName:  functionName,
+		functionARN:   functionARN,
+		clientContext: string(cc),
+		payload:       payload,
+		result:        make(chan *localResult, 1),
+		done:          make(chan struct{}),
+	}, nil
+}
+
+// respond sends the result to the caller.
+// It reports false if the result has already been sent.
+func (inv *localInvocation) respond(res *localResult) bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.responded {
+		return false
+	}
+	inv.responded = true
+	inv.result <- res
+	return true
+}
+
+// fail sends the error payload to the caller.
+// If the function has already started streaming the response, the stream is interrupted instead.
+func (inv *localInvocation) fail(payload []byte) {
+	if inv.respond(&localResult{payload: payload}) {
+		return
+	}
+
+	inv.mu.Lock()
+	stream := inv.stream
+	inv.mu.Unlock()
+	if stream != nil {
+		var e runtimeError
+		json.Unmarshal(payload, &e)
+		stream.CloseWithError(&ResponseStreamError{
+			ErrorCode:    e.ErrorType,
+			ErrorDetails: e.ErrorMessage,
+		})
+	}
+}
+
+func (inv *localInvocation) finish() {
+	inv.doneOnce.Do(func() {
+		close(inv.done)
+	})
+}
+
+// wait waits for the result of the invocation.
+// If ctx is done, the result is discarded in background.
+func (inv *localInvocation) wait(ctx context.Context) (*localResult, error) {
+	select {
+	case res := <-inv.result:
+		return res, nil
+	case <-ctx.Done():
+		go func() {
+			res := <-inv.result
+			res.close()
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func (inv *localInvocation) metadata() middleware.Metadata {
+	var md middleware.Metadata
+	awsmiddleware.SetRequestIDMetadata(&md, inv.id)
+	return md
+}
+
+// readAll reads the whole response.
+// If the invocation failed, it returns the error payload and the type of the function error.
+func (res *localResult) readAll() (payload []byte, functionError string, err error) {
+	if res.stream == nil {
+		return res.payload, "Unhandled", nil
+	}
+	defer res.stream.Close()
+
+	payload, err = io.ReadAll(res.stream)
+	var streamErr *ResponseStreamError
+	if errors.As(err, &streamErr) {
+		return marshalRuntimeError(streamErr.ErrorCode, streamErr.ErrorDetails), "Unhandled", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, "", nil
+}
+
+func (res *localResult) close() {
+	if res.stream != nil {
+		res.stream.Close()
+	}
+}
+
+// streamingPrelude is the prelude of the response of streaming functions.
+type streamingPrelude struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Cookies           []string            `json:"cookies,omitempty"`
+}
+
+// bufferStreamingResponse converts the response of a streaming function into the JSON payload of buffered functions.
+func bufferStreamingResponse(data []byte) ([]byte, error) {
+	prelude, body, ok := bytes.Cut(data, separate)
+	if !ok {
+		return nil, errors.New("lambtrip: the response stream does not have the prelude")
+	}
+	var resp response
+	if err := json.Unmarshal(prelude, &resp); err != nil {
+		return nil, fmt.Errorf("lambtrip: failed to parse the prelude: %w", err)
+	}
+	resp.Body = base64.StdEncoding.EncodeToString(body)
+	resp.IsBase64Encoded = true
+	return json.Marshal(resp)
+}
+
+// streamBufferedResponse converts the response of a buffered function into the response stream of streaming functions.
+func streamBufferedResponse(res *localResult) (*localResult, error) {
+	payload, functionError, err := res.readAll()
+	if err != nil {
+		return nil, err
+	}
+	if functionError != "" {
+		return &localResult{payload: payload}, nil
+	}
+
+	var resp response
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return nil, fmt.Errorf("lambtrip: failed to parse the response: %w", err)
+	}
+	body, _, err := resp.body()
+	if err != nil {
+		return nil, fmt.Errorf("lambtrip: failed to decode the response body: %w", err)
+	}
+	prelude, err := json.Marshal(streamingPrelude{
+		StatusCode:        resp.statusCode(),
+		Headers:           resp.Headers,
+		MultiValueHeaders: resp.MultiValueHeaders,
+		Cookies:           resp.Cookies,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &localResult{
+		stream:      io.NopCloser(io.MultiReader(bytes.NewReader(prelude), bytes.NewReader(separate), body)),
+		contentType: streamingResponseContentType,
+	}, nil
+}
+
+type localStreamGetter struct {
+	stream *lambda.InvokeWithResponseStreamEventStream
+}
+
+func (g localStreamGetter) GetStream() *lambda.InvokeWithResponseStreamEventStream {
+	return g.stream
+}
+
+var _ lambda.InvokeWithResponseStreamResponseEventReader = (*localEventReader)(nil)
+
+// localEventReader converts the result of the invocation into the events of the response stream.
+type localEventReader struct {
+	res    *localResult
+	ch     chan types.InvokeWithResponseStreamResponseEvent
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newLocalEventReader(res *localResult) *localEventReader {
+	r := &localEventReader{
+		res:    res,
+		ch:     make(chan types.InvokeWithResponseStreamResponseEvent),
+		closed: make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *localEventReader) run() {
+	defer close(r.ch)
+
+	if r.res.stream == nil {
+		var e runtimeError
+		json.Unmarshal(r.res.payload, &e)
+		r.send(&types.InvokeWithResponseStreamResponseEventMemberInvokeComplete{
+			Value: types.InvokeWithResponseStreamCompleteEvent{
+				ErrorCode:    aws.String(e.ErrorType),
+				ErrorDetails: aws.String(e.ErrorMessage),
+			},
+		})
+		return
+	}
+
+	for {
+		buf := make([]byte, 32*1024)
+		n, err := r.res.stream.Read(buf)
+		if n > 0 {
+			ok := r.send(&types.InvokeWithResponseStreamResponseEventMemberPayloadChunk{
+				Value: types.InvokeResponseStreamUpdate{
+					Payload: buf[:n],
+				},
+			})
+			if !ok {
+				return
+			}
+		}
+		if err == io.EOF {
+			r.send(&types.InvokeWithResponseStreamResponseEventMemberInvokeComplete{})
+			return
+		}
+		if err != nil {
+			complete := types.InvokeWithResponseStreamCompleteEvent{
+				ErrorCode:    aws.String("Runtime.Unknown"),
+				ErrorDetails: aws.String(err.Error()),
+			}
+			var streamErr *ResponseStreamError
+			if errors.As(err, &streamErr) {
+				complete.ErrorCode = aws.String(streamErr.ErrorCode)
+				complete.ErrorDetails = aws.String(streamErr.ErrorDetails)
+			}
+			r.send(&types.InvokeWithResponseStreamResponseEventMemberInvokeComplete{
+				Value: complete,
+			})
+			return
+		}
+	}
+}
+
+func (r *localEventReader) send(event types.InvokeWithResponseStreamResponseEvent) bool {
+	select {
+	case r.ch <- event:
+		return true
+	case <-r.closed:
+		return false
+	}
+}
+
+func (r *localEventReader) Events() <-chan types.InvokeWithResponseStreamResponseEvent {
+	return r.ch
+}
+
+func (r *localEventReader) Close() error {
+	r.once.Do(func() {
+		close(r.closed)
+		r.res.close()
+	})
+	return nil
+}
+
+func (r *localEventReader) Err() error {
+	return nil
+}
+
+// runtimeErrorPayload returns the error payload reported by the function.
+// If the body is not a JSON object, it is wrapped in the error payload.
+func runtimeErrorPayload(errorType string, body []byte) []byte {
+	var e runtimeError
+	if err := json.Unmarshal(body, &e); err == nil && (e.ErrorType != "" || e.ErrorMessage != "") {
+		return body
+	}
+	if errorType == "" {
+		errorType = "Runtime.Unknown"
+	}
+	return marshalRuntimeError(errorType, string(body))
+}
+
+// decodeRuntimeErrorBody returns the error message in the base64-encoded error body of the trailer.
+func decodeRuntimeErrorBody(s string) string {
+	body, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return s
+	}
+	var e runtimeError
+	if err := json.Unmarshal(body, &e); err == nil && e.ErrorMessage != "" {
+		return e.ErrorMessage
+	}
+	return string(body)
+}
+
+// runtimeError is the error payload of the Lambda Runtime API.
+type runtimeError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorType    string `json:"errorType"`
+}
+
+func marshalRuntimeError(errorType, errorMessage string) []byte {
+	data, _ := json.Marshal(runtimeError{
+		ErrorMessage: errorMessage,
+		ErrorType:    errorType,
+	})
+	return data
+}
+
+func writeRuntimeAPIError(w http.ResponseWriter, statusCode int, errorType, errorMessage string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(marshalRuntimeError(errorType, errorMessage))
+}
+
+func writerOrStderr(w io.Writer) io.Writer {
+	if w == nil {
+		return os.Stderr
+	}
+	return w
+}
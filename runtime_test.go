@@ -0,0 +1,297 @@
+package lambtrip
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	lambdasdk "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// TestLocalRuntimeHelperProcess isn't a real test.
+// It is used as the function binary run by LocalRuntime.
+func TestLocalRuntimeHelperProcess(t *testing.T) {
+	name := os.Getenv("LAMBTRIP_TEST_FUNCTION")
+	if name == "" {
+		return
+	}
+
+	switch name {
+	case "echo":
+		lambda.Start(func(ctx context.Context, req events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+			lc, _ := lambdacontext.FromContext(ctx)
+			return events.LambdaFunctionURLResponse{
+				StatusCode: http.StatusCreated,
+				Headers: map[string]string{
+					"Content-Type": "text/plain",
+				},
+				Body: req.RawPath + " " + req.Body + " " + lc.InvokedFunctionArn + " " + lc.ClientContext.Custom["foo"],
+			}, nil
+		})
+	case "stream":
+		lambda.Start(func(ctx context.Context, req events.LambdaFunctionURLRequest) (*events.LambdaFunctionURLStreamingResponse, error) {
+			return &events.LambdaFunctionURLStreamingResponse{
+				StatusCode: http.StatusOK,
+				Headers: map[string]string{
+					"Content-Type": "text/plain",
+				},
+				Body: strings.NewReader("hello " + req.RawPath),
+			}, nil
+		})
+	case "error":
+		lambda.Start(func(ctx context.Context) error {
+			return errors.New("something went wrong")
+		})
+	case "exit":
+		lambda.Start(func(ctx context.Context) error {
+			os.Exit(1)
+			return nil
+		})
+	case "sleep":
+		lambda.Start(func(ctx context.Context) error {
+			time.Sleep(time.Minute)
+			return nil
+		})
+	}
+	os.Exit(2)
+}
+
+func newTestLocalRuntime(t *testing.T, name string) *LocalRuntime {
+	t.Helper()
+	r, err := NewLocalRuntime(os.Args[0], "-test.run=^TestLocalRuntimeHelperProcess$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Env = []string{"LAMBTRIP_TEST_FUNCTION=" + name}
+	r.Timeout = 10 * time.Second
+	r.Stderr = io.Discard
+	t.Cleanup(func() {
+		r.Close()
+	})
+	return r
+}
+
+func TestLocalRuntime_Buffered(t *testing.T) {
+	r := newTestLocalRuntime(t, "echo")
+	transport := NewLocalBufferedTransport(r)
+
+	for i := 0; i < 2; i++ {
+		ctx := WithClientContext(context.Background(), &ClientContext{
+			Custom: map[string]string{"foo": "bar"},
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "lambda://live@function-name/foo", strings.NewReader("body"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusCreated)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+			t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", got, "text/plain")
+		}
+		want := "/foo body arn:aws:lambda:us-east-1:123456789012:function:function-name:live bar"
+		if string(body) != want {
+			t.Errorf("body = %q, want %q", string(body), want)
+		}
+	}
+}
+
+func TestLocalRuntime_ResponseStream(t *testing.T) {
+	r := newTestLocalRuntime(t, "stream")
+	transport := NewLocalResponseStreamTransport(r)
+
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", got, "text/plain")
+	}
+	if string(body) != "hello /foo" {
+		t.Errorf("body = %q, want %q", string(body), "hello /foo")
+	}
+}
+
+func TestLocalRuntime_StreamingFunctionWithBufferedTransport(t *testing.T) {
+	r := newTestLocalRuntime(t, "stream")
+	transport := NewLocalBufferedTransport(r)
+
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", got, "text/plain")
+	}
+	if string(body) != "hello /foo" {
+		t.Errorf("body = %q, want %q", string(body), "hello /foo")
+	}
+}
+
+func TestLocalRuntime_BufferedFunctionWithResponseStreamTransport(t *testing.T) {
+	r := newTestLocalRuntime(t, "echo")
+	transport := NewLocalResponseStreamTransport(r)
+
+	req, err := http.NewRequest(http.MethodPost, "lambda://function-name/foo", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", got, "text/plain")
+	}
+	want := "/foo body arn:aws:lambda:us-east-1:123456789012:function:function-name "
+	if string(body) != want {
+		t.Errorf("body = %q, want %q", string(body), want)
+	}
+}
+
+func TestLocalRuntime_Event(t *testing.T) {
+	r := newTestLocalRuntime(t, "sleep")
+
+	// the asynchronous invocations return without waiting for the previous invocation.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		out, err := r.Invoke(ctx, &lambdasdk.InvokeInput{
+			FunctionName:   aws.String("function-name"),
+			InvocationType: types.InvocationTypeEvent,
+			Payload:        []byte(`{}`),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.StatusCode != http.StatusAccepted {
+			t.Errorf("out.StatusCode = %d, want %d", out.StatusCode, http.StatusAccepted)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		t.Errorf("the invocations are blocked: %v", err)
+	}
+}
+
+func TestLocalRuntime_FunctionError(t *testing.T) {
+	r := newTestLocalRuntime(t, "error")
+	transport := NewLocalBufferedTransport(r)
+
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = transport.RoundTrip(req)
+	var fnErr *FunctionError
+	if !errors.As(err, &fnErr) {
+		t.Fatalf("want FunctionError, got %v", err)
+	}
+	if fnErr.ErrorMessage != "something went wrong" {
+		t.Errorf("ErrorMessage = %q, want %q", fnErr.ErrorMessage, "something went wrong")
+	}
+	if fnErr.ErrorType != "errorString" {
+		t.Errorf("ErrorType = %q, want %q", fnErr.ErrorType, "errorString")
+	}
+}
+
+func TestLocalRuntime_ExitError(t *testing.T) {
+	r := newTestLocalRuntime(t, "exit")
+	transport := NewLocalBufferedTransport(r)
+
+	// the process starts again on the next invocation.
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodGet, "lambda://function-name/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = transport.RoundTrip(req)
+		var fnErr *FunctionError
+		if !errors.As(err, &fnErr) {
+			t.Fatalf("want FunctionError, got %v", err)
+		}
+		if fnErr.ErrorType != "Runtime.ExitError" {
+			t.Errorf("ErrorType = %q, want %q", fnErr.ErrorType, "Runtime.ExitError")
+		}
+	}
+}
+
+func TestLocalRuntime_Timeout(t *testing.T) {
+	r := newTestLocalRuntime(t, "sleep")
+	r.Timeout = time.Second
+	transport := NewLocalResponseStreamTransport(r)
+	transport.ErrorResponse = true
+
+	req, err := http.NewRequest(http.MethodGet, "lambda://function-name/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+	if got := resp.Header.Get("X-Amzn-Errortype"); got != "Sandbox.Timedout" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "X-Amzn-Errortype", got, "Sandbox.Timedout")
+	}
+}
@@ -0,0 +1,107 @@
+package lambtrip
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// withSourceIP returns a shallow copy of req whose RemoteAddr is the IP address of the original client.
+// If the peer of req is one of trustedProxies,
+// the client is looked up from the Forwarded header or the X-Forwarded-For header.
+func withSourceIP(req *http.Request, trustedProxies []netip.Prefix) *http.Request {
+	if len(trustedProxies) == 0 {
+		return req
+	}
+	addr, ok := clientIP(req.RemoteAddr, req.Header, trustedProxies)
+	if !ok {
+		return req
+	}
+
+	r := new(http.Request)
+	*r = *req
+	r.RemoteAddr = addr.String()
+	return r
+}
+
+// sourceIP returns the IP address part of req.RemoteAddr.
+func sourceIP(req *http.Request) string {
+	addr, ok := parseAddr(req.RemoteAddr)
+	if !ok {
+		return ""
+	}
+	return addr.String()
+}
+
+// clientIP returns the IP address of the original client.
+// It walks the proxy chain from the nearest hop, and returns the first address that is not trusted.
+func clientIP(remoteAddr string, header http.Header, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	addr, ok := parseAddr(remoteAddr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+
+	hops := forwardedFor(header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrustedProxy(addr, trustedProxies) {
+			break
+		}
+		hop, ok := parseAddr(hops[i])
+		if !ok {
+			// the address is obfuscated or unknown.
+			break
+		}
+		addr = hop
+	}
+	return addr, true
+}
+
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the addresses of the proxy chain, from the farthest to the nearest.
+// It prefers the Forwarded header (RFC 7239) to the X-Forwarded-For header.
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	if forwarded := header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, v := range forwarded {
+			for _, elem := range strings.Split(v, ",") {
+				for _, pair := range strings.Split(elem, ";") {
+					key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						hops = append(hops, strings.Trim(value, `"`))
+					}
+				}
+			}
+		}
+		return hops
+	}
+
+	for _, v := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// parseAddr parses an IP address with an optional port number.
+// e.g. "192.0.2.1", "192.0.2.1:8080", "2001:db8::1", "[2001:db8::1]:8080"
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
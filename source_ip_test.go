@@ -0,0 +1,149 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestBufferedTransport_SourceIP(t *testing.T) {
+	transport := &BufferedTransport{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req request
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if req.RequestContext.HTTP.SourceIP != "192.0.2.1" {
+				t.Errorf("req.RequestContext.HTTP.SourceIP = %q, want %q", req.RequestContext.HTTP.SourceIP, "192.0.2.1")
+			}
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"body": "ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "10.0.0.1:54321"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1, 10.0.0.2")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if req.RemoteAddr != "10.0.0.1:54321" {
+		t.Errorf("the original request is modified: req.RemoteAddr = %q", req.RemoteAddr)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:ffff::/48"),
+	}
+	tests := []struct {
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		// no proxies
+		{
+			remoteAddr: "192.0.2.1:12345",
+			want:       "192.0.2.1",
+		},
+
+		// the peer is not trusted
+		{
+			remoteAddr: "192.0.2.1:12345",
+			header: http.Header{
+				"X-Forwarded-For": []string{"198.51.100.1"},
+			},
+			want: "192.0.2.1",
+		},
+
+		// the peer is trusted
+		{
+			remoteAddr: "10.0.0.1:12345",
+			header: http.Header{
+				"X-Forwarded-For": []string{"198.51.100.1"},
+			},
+			want: "198.51.100.1",
+		},
+
+		// multiple trusted proxies
+		{
+			remoteAddr: "10.0.0.1:12345",
+			header: http.Header{
+				"X-Forwarded-For": []string{"203.0.113.1, 198.51.100.1, 10.0.0.2"},
+			},
+			want: "198.51.100.1",
+		},
+
+		// multiple headers
+		{
+			remoteAddr: "10.0.0.1:12345",
+			header: http.Header{
+				"X-Forwarded-For": []string{"198.51.100.1", "10.0.0.2"},
+			},
+			want: "198.51.100.1",
+		},
+
+		// all hops are trusted
+		{
+			remoteAddr: "10.0.0.1:12345",
+			header: http.Header{
+				"X-Forwarded-For": []string{"10.0.0.3, 10.0.0.2"},
+			},
+			want: "10.0.0.3",
+		},
+
+		// Forwarded header
+		{
+			remoteAddr: "10.0.0.1:12345",
+			header: http.Header{
+				"Forwarded":       []string{`for=192.0.2.43, for="[2001:db8:cafe::17]:4711";proto=https`},
+				"X-Forwarded-For": []string{"198.51.100.1"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+
+		// obfuscated identifier
+		{
+			remoteAddr: "10.0.0.1:12345",
+			header: http.Header{
+				"Forwarded": []string{`for=_hidden`},
+			},
+			want: "10.0.0.1",
+		},
+
+		// IPv6 peer
+		{
+			remoteAddr: "[2001:db8:ffff::1]:12345",
+			header: http.Header{
+				"X-Forwarded-For": []string{"192.0.2.1"},
+			},
+			want: "192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := clientIP(tt.remoteAddr, tt.header, trusted)
+		if !ok {
+			t.Errorf("clientIP(%q, %v) failed", tt.remoteAddr, tt.header)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("clientIP(%q, %v) = %q, want %q", tt.remoteAddr, tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package lambtrip
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultServiceNetworkARN is a dummy ARN of the VPC Lattice service network.
+	defaultServiceNetworkARN = "arn:aws:vpc-lattice:us-east-1:123456789012:servicenetwork/sn-0123456789abcdef0"
+
+	// defaultServiceARN is a dummy ARN of the VPC Lattice service.
+	defaultServiceARN = "arn:aws:vpc-lattice:us-east-1:123456789012:service/svc-0123456789abcdef0"
+
+	// defaultLatticeTargetGroupARN is a dummy ARN of the VPC Lattice target group.
+	defaultLatticeTargetGroupARN = "arn:aws:vpc-lattice:us-east-1:123456789012:targetgroup/tg-0123456789abcdef0"
+)
+
+// requestLatticeV1 is the event format version 1.0 of VPC Lattice.
+type requestLatticeV1 struct {
+	RawPath               string            `json:"raw_path"`
+	Method                string            `json:"method"`
+	Headers               map[string]string `json:"headers"`
+	QueryStringParameters map[string]string `json:"query_string_parameters"`
+	Body                  string            `json:"body"`
+	IsBase64Encoded       bool              `json:"is_base64_encoded"`
+}
+
+// requestLatticeV2 is the event format version 2.0 of VPC Lattice.
+type requestLatticeV2 struct {
+	Version               string                   `json:"version"`
+	Path                  string                   `json:"path"`
+	Method                string                   `json:"method"`
+	Headers               map[string][]string      `json:"headers"`
+	QueryStringParameters map[string]string        `json:"queryStringParameters,omitempty"`
+	Body                  string                   `json:"body"`
+	IsBase64Encoded       bool                     `json:"isBase64Encoded"`
+	RequestContext        *requestContextLatticeV2 `json:"requestContext"`
+}
+
+type requestContextLatticeV2 struct {
+	ServiceNetworkARN string                           `json:"serviceNetworkArn"`
+	ServiceARN        string                           `json:"serviceArn"`
+	TargetGroupARN    string                           `json:"targetGroupArn"`
+	Identity          *requestContextLatticeV2Identity `json:"identity"`
+	Region            string                           `json:"region"`
+
+	// TimeEpoch is the time of the request in microseconds.
+	TimeEpoch string `json:"timeEpoch"`
+}
+
+type requestContextLatticeV2Identity struct {
+	SourceVPCARN string `json:"sourceVpcArn,omitempty"`
+	Type         string `json:"type,omitempty"`
+}
+
+func buildRequestLatticeV1(req *http.Request) (*requestLatticeV1, error) {
+	// build the body
+	body, isBase64Encoded, err := buildBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// build the headers
+	headers := make(map[string]string, len(req.Header))
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+
+	return &requestLatticeV1{
+		RawPath:               req.URL.RequestURI(),
+		Method:                req.Method,
+		Headers:               headers,
+		QueryStringParameters: buildLatticeQuery(req),
+		Body:                  body,
+		IsBase64Encoded:       isBase64Encoded,
+	}, nil
+}
+
+func buildRequestLatticeV2(req *http.Request) (*requestLatticeV2, error) {
+	now := time.Now().UTC()
+
+	// build the body
+	body, isBase64Encoded, err := buildBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// build the headers
+	headers := make(map[string][]string, len(req.Header))
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		headers[name] = append(headers[name], v...)
+	}
+
+	return &requestLatticeV2{
+		Version:               "2.0",
+		Path:                  req.URL.EscapedPath(),
+		Method:                req.Method,
+		Headers:               headers,
+		QueryStringParameters: buildLatticeQuery(req),
+		Body:                  body,
+		IsBase64Encoded:       isBase64Encoded,
+		RequestContext: &requestContextLatticeV2{
+			ServiceNetworkARN: defaultServiceNetworkARN,
+			ServiceARN:        defaultServiceARN,
+			TargetGroupARN:    defaultLatticeTargetGroupARN,
+			Identity: &requestContextLatticeV2Identity{
+				Type: "NONE",
+			},
+			Region:    "us-east-1",
+			TimeEpoch: strconv.FormatInt(now.UnixMicro(), 10),
+		},
+	}, nil
+}
+
+// buildLatticeQuery builds the query string parameters.
+// If the client sends duplicate parameters, the last value is used.
+func buildLatticeQuery(req *http.Request) map[string]string {
+	q := req.URL.Query()
+	if len(q) == 0 {
+		return nil
+	}
+	query := make(map[string]string, len(q))
+	for k, v := range q {
+		query[k] = v[len(v)-1]
+	}
+	return query
+}
@@ -0,0 +1,152 @@
+package lambtrip
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestBufferedTransport_VPCLatticeV1(t *testing.T) {
+	transport := &BufferedTransport{
+		Codec: EventFormatVPCLatticeV1,
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req requestLatticeV1
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if req.RawPath != "/foo/bar?q=a" {
+				t.Errorf("req.RawPath = %q, want %q", req.RawPath, "/foo/bar?q=a")
+			}
+			if req.Method != http.MethodPut {
+				t.Errorf("req.Method = %q, want %q", req.Method, http.MethodPut)
+			}
+			if got := req.Headers["x-foo"]; got != "bar1,bar2" {
+				t.Errorf("req.Headers[%q] = %q, want %q", "x-foo", got, "bar1,bar2")
+			}
+			if got := req.QueryStringParameters["q"]; got != "a" {
+				t.Errorf("req.QueryStringParameters[%q] = %q, want %q", "q", got, "a")
+			}
+			if !req.IsBase64Encoded {
+				t.Errorf("req.IsBase64Encoded = %v, want %v", req.IsBase64Encoded, true)
+			}
+			if req.Body != "AAEC" {
+				t.Errorf("req.Body = %q, want %q", req.Body, "AAEC")
+			}
+
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"statusCode":200,"statusDescription":"200 OK","headers":{"content-type":"text/plain"},"body":"b2s=","isBase64Encoded":true}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "lambda://function-name/foo/bar?q=a", strings.NewReader("\x00\x01\x02"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Add("X-Foo", "bar1")
+	req.Header.Add("X-Foo", "bar2")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Status != "200 OK" {
+		t.Errorf("resp.Status = %q, want %q", resp.Status, "200 OK")
+	}
+	if resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("resp.Header.Get(%q) = %q, want %q", "Content-Type", resp.Header.Get("Content-Type"), "text/plain")
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "ok" {
+		t.Errorf("body = %q, want %q", string(body), "ok")
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBufferedTransport_VPCLatticeV2(t *testing.T) {
+	transport := &BufferedTransport{
+		Codec: EventFormatVPCLatticeV2,
+		lambda: InvokeMock(func(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			var req requestLatticeV2
+			if err := json.Unmarshal(params.Payload, &req); err != nil {
+				return nil, err
+			}
+			if req.Version != "2.0" {
+				t.Errorf("req.Version = %q, want %q", req.Version, "2.0")
+			}
+			if req.Path != "/foo/bar" {
+				t.Errorf("req.Path = %q, want %q", req.Path, "/foo/bar")
+			}
+			if req.Method != http.MethodGet {
+				t.Errorf("req.Method = %q, want %q", req.Method, http.MethodGet)
+			}
+			if got, want := req.Headers["x-foo"], []string{"bar1", "bar2"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("req.Headers[%q] = %q, want %q", "x-foo", got, want)
+			}
+			if got := req.QueryStringParameters["q"]; got != "b c" {
+				t.Errorf("req.QueryStringParameters[%q] = %q, want %q", "q", got, "b c")
+			}
+			if req.RequestContext.ServiceNetworkARN == "" {
+				t.Errorf("req.RequestContext.ServiceNetworkARN = %q, want non-empty", req.RequestContext.ServiceNetworkARN)
+			}
+			if req.RequestContext.TimeEpoch == "" {
+				t.Errorf("req.RequestContext.TimeEpoch = %q, want non-empty", req.RequestContext.TimeEpoch)
+			}
+
+			return &lambda.InvokeOutput{
+				StatusCode: http.StatusOK,
+				Payload:    []byte(`{"statusCode":200,"body":"ok"}`),
+			}, nil
+		}),
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar?q=b+c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("X-Foo", "bar1")
+	req.Header.Add("X-Foo", "bar2")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("resp.StatusCode = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestTransport_VPCLatticeIsNotSupported(t *testing.T) {
+	transport := &ResponseStreamTransport{
+		Codec: EventFormatVPCLatticeV2,
+		lambda: func(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*invokeWithResponseStreamOutput, error) {
+			t.Error("the lambda function should not be invoked")
+			return nil, io.ErrUnexpectedEOF
+		},
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "lambda://function-name/foo/bar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("want error, got nil")
+	}
+}